@@ -0,0 +1,72 @@
+package face
+
+import (
+	"image"
+)
+
+// SkinMaskAdaptive is like SkinMask, but adapts the luminance gates
+// to the neighborhood of each pixel rather than to the whole image,
+// recovering skin across an illumination gradient such as a face lit
+// from one side. The gates are scaled as for Options.AdaptLuminance
+// using the mean luma (r+g+b)/3 of the window×window pixels centered
+// on each pixel, clipped to the image; the chroma tests are
+// unchanged. The means come from an integral image, so the cost does
+// not depend on window. A window less than 1 is treated as 1.
+func SkinMaskAdaptive(src image.Image, window int) (*image.Alpha, float64) {
+	if window < 1 {
+		window = 1
+	}
+	img := toRGBA(src)
+	r := img.Bounds()
+	w, h := r.Dx(), r.Dy()
+	mask := image.NewAlpha(r)
+	if w == 0 || h == 0 {
+		return mask, 0
+	}
+
+	// sum[(y)*(w+1)+x] is the luma sum of the pixels above and left
+	// of (x, y).
+	sum := make([]int, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		sp, row := img.PixOffset(r.Min.X, r.Min.Y+y), 0
+		for x := 0; x < w; x, sp = x+1, sp+4 {
+			p := img.Pix[sp : sp+3 : sp+3]
+			row += (int(p[0]) + int(p[1]) + int(p[2])) / 3
+			sum[(y+1)*(w+1)+x+1] = sum[y*(w+1)+x+1] + row
+		}
+	}
+
+	// The gates depend on the mean only through its integer part.
+	var skin [256]func(r, g, b uint8) bool
+	for i := range skin {
+		skin[i] = defaultThresholds.adapt(float64(i)).skin()
+	}
+
+	lo, hi := window/2, window-window/2
+	m := 0
+	for y := 0; y < h; y++ {
+		y0, y1 := clampInt(y-lo, 0, h), clampInt(y+hi, 0, h)
+		sp, mp := img.PixOffset(r.Min.X, r.Min.Y+y), mask.PixOffset(r.Min.X, r.Min.Y+y)
+		for x := 0; x < w; x, sp, mp = x+1, sp+4, mp+1 {
+			x0, x1 := clampInt(x-lo, 0, w), clampInt(x+hi, 0, w)
+			s := boxSum(sum, w, x0, y0, x1, y1)
+			mean := s / ((x1 - x0) * (y1 - y0))
+			p := img.Pix[sp : sp+3 : sp+3]
+			if skin[mean](p[0], p[1], p[2]) {
+				mask.Pix[mp] = 255
+				m++
+			}
+		}
+	}
+	return mask, coverage(m, w*h)
+}
+
+func clampInt(v, lo, hi int) int {
+	switch {
+	case v < lo:
+		return lo
+	case v > hi:
+		return hi
+	}
+	return v
+}
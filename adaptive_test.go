@@ -0,0 +1,59 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// shadedFace draws a disk of skin on a dark background, lit from the
+// left so that its right side falls to a fifth of the brightness.
+func shadedFace() (img *image.RGBA, face func(x, y int) bool) {
+	const (
+		w, h   = 120, 90
+		cx, cy = 60, 45
+		rad    = 36
+	)
+	img = image.NewRGBA(image.Rect(0, 0, w, h))
+	face = func(x, y int) bool { return (x-cx)*(x-cx)+(y-cy)*(y-cy) <= rad*rad }
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{20, 24, 40, 255}
+			if face(x, y) {
+				k := 1 - 0.8*float64(x-(cx-rad))/(2*rad)
+				c = color.RGBA{uint8(215 * k), uint8(150 * k), uint8(120 * k), 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img, face
+}
+
+// skinDisk returns the mask of the pixels of r where face is true.
+func skinDisk(r image.Rectangle, face func(x, y int) bool) *image.Alpha {
+	m := image.NewAlpha(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if face(x, y) {
+				m.SetAlpha(x, y, color.Alpha{255})
+			}
+		}
+	}
+	return m
+}
+
+func TestSkinMaskAdaptiveShaded(t *testing.T) {
+	src, face := shadedFace()
+	global, _ := SkinMask(src, nil)
+	adaptive, _ := SkinMaskAdaptive(src, 15)
+	area, _ := countSet(skinDisk(src.Bounds(), face), face)
+	gi, _ := countSet(global, face)
+	ai, ao := countSet(adaptive, face)
+	t.Logf("face pixels: %d, global %d, adaptive %d", area, gi, ai)
+	if ai <= gi || ai < area*95/100 {
+		t.Errorf("adaptive found %d of %d face pixels, global %d; want most and more than global", ai, area, gi)
+	}
+	if ao != 0 {
+		t.Errorf("adaptive marked %d background pixels", ao)
+	}
+}
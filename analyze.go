@@ -0,0 +1,141 @@
+package face
+
+import (
+	"image"
+	"math"
+)
+
+// Reason explains why Analyze rejected an image.
+type Reason int
+
+const (
+	ReasonNone       Reason = iota // not rejected
+	ReasonEmpty                    // the image has no pixels
+	ReasonGrayscale                // the image is achromatic; see SelectMode
+	ReasonPosterized               // the image is flat artwork; see Content
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonNone:
+		return "none"
+	case ReasonEmpty:
+		return "empty"
+	case ReasonGrayscale:
+		return "grayscale"
+	case ReasonPosterized:
+		return "posterized"
+	}
+	return "Reason(?)"
+}
+
+// Result is the outcome of Analyze.
+type Result struct {
+	Mask      *image.Alpha // the skin mask, nil if not processed
+	Cover     float64      // the skin coverage
+	Processed bool         // whether detection ran
+	Reason    Reason       // why detection did not run
+}
+
+// Analyze screens src before detecting skin in it, so that an image
+// where no skin was found can be told apart from one that was never
+// examined. Empty images, achromatic images and posterized images,
+// those rated 64 or less by Content, are rejected with the
+// corresponding reason. Otherwise the result holds the mask and
+// coverage of SkinMask.
+func Analyze(src image.Image) Result {
+	const (
+		minContent = 64
+	)
+	r := src.Bounds()
+	switch {
+	case r.Empty():
+		return Result{Reason: ReasonEmpty}
+	case SelectMode(src) == ModeNone:
+		return Result{Reason: ReasonGrayscale}
+	case Content(src, r) <= minContent:
+		return Result{Reason: ReasonPosterized}
+	}
+	mask, cover := skinMaskColor(src, nil, &Options{})
+	return Result{Mask: mask.(*image.Alpha), Cover: cover, Processed: true}
+}
+
+// Summary bundles the common outputs of detection on one image, for
+// logging. It marshals directly to JSON.
+type Summary struct {
+	Coverage      float64 `json:"coverage"`      // skin coverage, as from SkinMask
+	ContentRating int     `json:"contentRating"` // as from Content over the whole image
+	RegionCount   int     `json:"regionCount"`   // 8-connected skin regions
+	LargestRegion Box     `json:"largestRegion"` // bounds of the largest region
+	Centroid      Point   `json:"centroid"`      // centroid of the largest region
+}
+
+// Box is a rectangle by its top-left corner and size.
+type Box struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+// Point is a pixel position.
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// AnalyzeSummary detects skin in src and summarizes the result.
+// Without skin, the largest region and centroid are zero.
+func AnalyzeSummary(src image.Image) Summary {
+	mask, cover := skinMaskColor(src, nil, &Options{})
+	return summarize(src, cover, Components(mask.(*image.Alpha), Conn8))
+}
+
+// summarize returns the Summary of the detection in src of the
+// components cs with the given coverage.
+func summarize(src image.Image, cover float64, cs []Component) Summary {
+	s := Summary{
+		Coverage:      cover,
+		ContentRating: int(Content(src, src.Bounds())),
+		RegionCount:   len(cs),
+	}
+	if c, ok := largest(cs); ok {
+		b := c.Bounds
+		s.LargestRegion = Box{b.Min.X, b.Min.Y, b.Dx(), b.Dy()}
+		s.Centroid = Point{c.Centroid.X, c.Centroid.Y}
+	}
+	return s
+}
+
+// HasFace reports whether src shows skin that looks like a face,
+// with one knob for callers who do not want to pick thresholds.
+// Sensitivity in [0, 1], clamped, runs from permissive to strict and
+// sets the cutoffs linearly between
+//
+//	                       0 (permissive)   1 (strict)
+//	largest region area    0.5% of image    5% of image
+//	largest region area    64 pixels        1024 pixels
+//	compactness            0.3              0.6
+//
+// where the largest region is the largest 8-connected region of the
+// mask of SkinMask, and its compactness is its area over that of its
+// bounding rectangle, as for LooseCoverage; a solid ellipse scores
+// about 0.785. All three must be met. Empty and achromatic images,
+// as judged by SelectMode, have no face.
+func HasFace(src image.Image, sensitivity float64) bool {
+	r := src.Bounds()
+	if r.Empty() || SelectMode(src) == ModeNone {
+		return false
+	}
+	s := math.Max(0, math.Min(1, sensitivity))
+	lerp := func(a, b float64) float64 { return a + s*(b-a) }
+	mask, _ := skinMaskColor(src, nil, &Options{})
+	c, ok := largest(Components(mask.(*image.Alpha), Conn8))
+	if !ok {
+		return false
+	}
+	area := float64(c.Area)
+	return area >= lerp(0.005, 0.05)*float64(r.Dx()*r.Dy()) &&
+		area >= lerp(64, 1024) &&
+		area >= lerp(0.3, 0.6)*float64(c.Bounds.Dx()*c.Bounds.Dy())
+}
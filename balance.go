@@ -0,0 +1,83 @@
+package face
+
+import (
+	"image"
+	"image/draw"
+)
+
+// SkinMaskBalanced is like SkinMask, but first corrects the color
+// cast of the light, such as the magenta or cyan of stage lighting,
+// under which the fixed thresholds find no skin. The illuminant is
+// estimated by white patch, as the mean color of the brightest 1% of
+// pixels sampled on a grid of at most 256×256, and each channel is
+// scaled so the illuminant becomes white, a von Kries correction.
+// The correction is applied to each pixel as it is classified; src
+// is not copied. If the brightest pixels are too dark to serve as a
+// white reference, below 64 in some channel, the detection is that
+// of SkinMask.
+func SkinMaskBalanced(src image.Image, mask draw.Image) (mask0 draw.Image, cover float64) {
+	lr, lg, lb, ok := whitePatch(src)
+	if !ok {
+		return skinMaskColor(src, mask, &Options{})
+	}
+	return skinMaskColor(src, mask, &Options{skin: func(r, g, b uint8) bool {
+		return skinRGB(lr[r], lg[g], lb[b])
+	}})
+}
+
+// whitePatch estimates the illuminant of src and returns the lookup
+// tables of the von Kries correction for each channel.
+func whitePatch(src image.Image) (lr, lg, lb *[256]uint8, ok bool) {
+	const (
+		grid     = 256
+		minWhite = 64
+	)
+	r := src.Bounds()
+	if r.Empty() {
+		return nil, nil, nil, false
+	}
+	dx := (r.Dx() + grid - 1) / grid
+	dy := (r.Dy() + grid - 1) / grid
+	type sample struct{ r, g, b uint8 }
+	var (
+		ps   []sample
+		hist [256]int
+	)
+	for y := r.Min.Y; y < r.Max.Y; y += dy {
+		for x := r.Min.X; x < r.Max.X; x += dx {
+			cr, cg, cb, _ := src.At(x, y).RGBA()
+			p := sample{}
+			p.r, p.g, p.b = to8(cr, cg, cb)
+			ps = append(ps, p)
+			hist[(int(p.r)+int(p.g)+int(p.b))/3]++
+		}
+	}
+	// The luma of the brightest 1%, and at least one sample.
+	floor, n := 255, 0
+	for ; floor > 0; floor-- {
+		if n += hist[floor]; n*100 >= len(ps) {
+			break
+		}
+	}
+	var sr, sg, sb, k int
+	for _, p := range ps {
+		if (int(p.r)+int(p.g)+int(p.b))/3 >= floor {
+			sr, sg, sb, k = sr+int(p.r), sg+int(p.g), sb+int(p.b), k+1
+		}
+	}
+	if sr < minWhite*k || sg < minWhite*k || sb < minWhite*k {
+		return nil, nil, nil, false
+	}
+	lut := func(sum int) *[256]uint8 {
+		var t [256]uint8
+		for i := range t {
+			if v := i * 255 * k / sum; v < 255 {
+				t[i] = uint8(v)
+			} else {
+				t[i] = 255
+			}
+		}
+		return &t
+	}
+	return lut(sr), lut(sg), lut(sb), true
+}
@@ -0,0 +1,42 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSkinMaskBalancedMagenta(t *testing.T) {
+	const (
+		w, h   = 120, 90
+		cx, cy = 45, 45
+		rad    = 30
+	)
+	face := func(x, y int) bool { return (x-cx)*(x-cx)+(y-cy)*(y-cy) <= rad*rad }
+	// Magenta stage light: green falls to 60%.
+	tint := func(c color.RGBA) color.RGBA { c.G = uint8(int(c.G) * 6 / 10); return c }
+	src := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{110, 112, 118, 255}
+			switch {
+			case face(x, y):
+				c = color.RGBA{200, 140, 110, 255}
+			case x >= 95 && y < 30: // a white shirt collar, the reference
+				c = color.RGBA{245, 245, 242, 255}
+			}
+			src.SetRGBA(x, y, tint(c))
+		}
+	}
+	area, _ := countSet(skinDisk(src.Rect, face), face)
+	plain, _ := SkinMask(src, nil)
+	balanced, _ := SkinMaskBalanced(src, nil)
+	pi, _ := countSet(plain, face)
+	bi, bo := countSet(balanced, face)
+	if pi > area/10 {
+		t.Errorf("SkinMask found %d of %d face pixels under the tint; the test needs it to fail", pi, area)
+	}
+	if bi < area*9/10 || bo != 0 {
+		t.Errorf("SkinMaskBalanced found %d of %d face pixels and %d others; want at least 90%% and none", bi, area, bo)
+	}
+}
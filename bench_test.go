@@ -0,0 +1,130 @@
+package face
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"testing"
+)
+
+// setMask hides the concrete type of a mask, forcing the path that
+// writes it pixel by pixel through Set.
+type setMask struct{ draw.Image }
+
+func BenchmarkMaskWrites(b *testing.B) {
+	// The mask extends a row past src, so the RGBA fast path is not
+	// taken either way.
+	src := randRGBA(image.Rect(0, 0, 640, 480), 1)
+	r := image.Rect(0, 0, 640, 481)
+	b.Run("Set", func(b *testing.B) {
+		mask := setMask{image.NewAlpha(r)}
+		for i := 0; i < b.N; i++ {
+			SkinMask(src, mask)
+		}
+	})
+	b.Run("Pix", func(b *testing.B) {
+		mask := image.NewAlpha(r)
+		for i := 0; i < b.N; i++ {
+			SkinMask(src, mask)
+		}
+	})
+}
+
+// floatSkin is IsSkin with its ratio test in floating point, as
+// before the integer comparison of ratio.
+func floatSkin(r, g, b uint8) bool {
+	return PassLuminance(r, g, b) && PassDelta(r, g, b) && g != 0 && float32(r)/float32(g) < 2.5
+}
+
+func BenchmarkRatio(b *testing.B) {
+	// Both classifiers are passed through Options.skin, so neither
+	// is inlined into the fast path.
+	src := randRGBA(image.Rect(0, 0, 2048, 2048), 2)
+	mask := image.NewAlpha(src.Rect)
+	for _, tc := range []struct {
+		name string
+		skin func(r, g, b uint8) bool
+	}{
+		{"Float", floatSkin},
+		{"Integer", skinRGB},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			b.SetBytes(int64(len(src.Pix)))
+			for i := 0; i < b.N; i++ {
+				SkinMaskWith(src, mask, Options{skin: tc.skin})
+			}
+		})
+	}
+}
+
+func BenchmarkSkinMaskRGBA(b *testing.B) {
+	src := randRGBA(image.Rect(0, 0, 640, 480), 4)
+	mask := image.NewAlpha(src.Rect)
+	b.Run("SkinMaskRGBA", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			SkinMaskRGBA(src, mask)
+		}
+	})
+	b.Run("SkinMask", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			SkinMask(src, mask)
+		}
+	})
+}
+
+func BenchmarkYCbCrRows(b *testing.B) {
+	// ModeHSV has no YCbCr fast path, so both cases take the
+	// generic one, reading the source through rowRGB or At.
+	src := randYCbCr(image.Rect(0, 0, 1000, 1000), image.YCbCrSubsampleRatio420, 5)
+	mask := image.NewAlpha(src.Rect)
+	opt := Options{Mode: ModeHSV}
+	b.Run("rowRGB", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			SkinMaskWith(src, mask, opt)
+		}
+	})
+	b.Run("At", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			SkinMaskWith(generic{src}, mask, opt)
+		}
+	})
+}
+
+func BenchmarkSkinMaskPooled(b *testing.B) {
+	src, _ := GenerateTestImage(6, 3)
+	b.Run("SkinMaskPooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_, release, _ := SkinMaskPooled(src)
+				release()
+			}
+		})
+	})
+	b.Run("SkinMask", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				SkinMask(src, nil)
+			}
+		})
+	})
+}
+
+func BenchmarkSkinCoverage(b *testing.B) {
+	r := image.Rect(0, 0, 1000, 1000)
+	rgba := randRGBA(r, 7)
+	srcs := []image.Image{rgba, atNRGBA(rgba), randYCbCr(r, image.YCbCrSubsampleRatio420, 7), image.NewGray(r)}
+	for _, src := range srcs {
+		b.Run(fmt.Sprintf("%T", src)[7:], func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				SkinCoverage(src)
+			}
+		})
+	}
+}
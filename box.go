@@ -0,0 +1,145 @@
+package face
+
+import (
+	"image"
+	"sort"
+)
+
+// SkinMaskRegions detects skin in src and returns the bounds of its
+// 8-connected regions of at least minArea pixels, largest first,
+// with the fraction of each box the region fills and the coverage
+// of the whole image. A solid face fills much of its box; a ragged
+// blob fills little.
+func SkinMaskRegions(src image.Image, minArea int) (regions []image.Rectangle, perRegionCover []float64, total float64) {
+	mask, total := skinMaskColor(src, nil, &Options{})
+	cs := Components(mask.(*image.Alpha), Conn8)
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].Area > cs[j].Area })
+	for _, c := range cs {
+		if c.Area < minArea {
+			break
+		}
+		regions = append(regions, c.Bounds)
+		perRegionCover = append(perRegionCover, coverage(c.Area, c.Bounds.Dx()*c.Bounds.Dy()))
+	}
+	return regions, perRegionCover, total
+}
+
+// Detect returns the bounds of the skin regions of src shaped like
+// faces, largest first. The 8-connected regions of the mask of
+// SkinMask are kept if they pass every filter:
+//
+//	area    at least 64 pixels and 0.1% of the image
+//	aspect  height over width in [0.6, 2.5]
+//	fill    area over that of the bounding box at least 0.4
+//
+// A face seen from the front is an upright ellipse, filling π/4 of
+// its box; speckle, strips of skin-colored background and ragged
+// blobs are dropped. Like FaceScore, it is a heuristic: the boxes
+// are candidates, not confirmed faces.
+func Detect(src image.Image) []image.Rectangle {
+	const (
+		minArea              = 64
+		minFrac              = 0.001
+		minAspect, maxAspect = 0.6, 2.5
+		minFill              = 0.4
+	)
+	r := src.Bounds()
+	mask, _ := skinMaskColor(src, nil, &Options{})
+	cs := Components(mask.(*image.Alpha), Conn8)
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].Area > cs[j].Area })
+	var boxes []image.Rectangle
+	for _, c := range cs {
+		b := c.Bounds
+		aspect := float64(b.Dy()) / float64(b.Dx())
+		switch {
+		case c.Area < minArea || float64(c.Area) < minFrac*float64(r.Dx()*r.Dy()):
+			continue
+		case aspect < minAspect || aspect > maxAspect:
+			continue
+		case float64(c.Area) < minFill*float64(b.Dx()*b.Dy()):
+			continue
+		}
+		boxes = append(boxes, b)
+	}
+	return boxes
+}
+
+// MergeVertical merges boxes that overlap horizontally and are at
+// most maxGap pixels apart vertically, such as a face and a neck
+// split by the shadow of the jaw. Merging repeats until no two boxes
+// qualify. The input slice is not modified; merged boxes take the
+// position of the first box they absorbed.
+func MergeVertical(boxes []image.Rectangle, maxGap int) []image.Rectangle {
+	out := append([]image.Rectangle(nil), boxes...)
+	for merged := true; merged; {
+		merged = false
+		for i := 0; i < len(out); i++ {
+			for j := i + 1; j < len(out); j++ {
+				if !stacked(out[i], out[j], maxGap) {
+					continue
+				}
+				out[i] = out[i].Union(out[j])
+				out = append(out[:j], out[j+1:]...)
+				j--
+				merged = true
+			}
+		}
+	}
+	return out
+}
+
+// stacked reports whether a and b overlap horizontally with at most
+// gap rows between them.
+func stacked(a, b image.Rectangle, gap int) bool {
+	if a.Min.X >= b.Max.X || b.Min.X >= a.Max.X {
+		return false
+	}
+	if a.Min.Y > b.Min.Y {
+		a, b = b, a
+	}
+	return b.Min.Y-a.Max.Y <= gap
+}
+
+// TrimLetterbox returns the bounds of src without the dark bars of
+// a letterboxed or pillarboxed frame, for detection on the active
+// picture only, such as through SubImage. A bar is a run of rows,
+// or columns, along an edge whose luma (r+g+b)/3 has a mean of at
+// most 24 and a standard deviation of at most 8, tolerating the
+// noise of compressed video. If there are no bars, or the whole
+// frame is dark, the bounds of src are returned.
+func TrimLetterbox(src image.Image) image.Rectangle {
+	r := src.Bounds()
+	t := r
+	for t.Min.Y < t.Max.Y && bar(src, image.Rect(t.Min.X, t.Min.Y, t.Max.X, t.Min.Y+1)) {
+		t.Min.Y++
+	}
+	for t.Max.Y > t.Min.Y && bar(src, image.Rect(t.Min.X, t.Max.Y-1, t.Max.X, t.Max.Y)) {
+		t.Max.Y--
+	}
+	for t.Min.X < t.Max.X && bar(src, image.Rect(t.Min.X, t.Min.Y, t.Min.X+1, t.Max.Y)) {
+		t.Min.X++
+	}
+	for t.Max.X > t.Min.X && bar(src, image.Rect(t.Max.X-1, t.Min.Y, t.Max.X, t.Max.Y)) {
+		t.Max.X--
+	}
+	if t.Empty() {
+		return r
+	}
+	return t
+}
+
+// bar reports whether the pixels of src within r are near-uniform
+// dark, as for TrimLetterbox.
+func bar(src image.Image, r image.Rectangle) bool {
+	const (
+		maxMean, maxDev = 24, 8
+	)
+	var lu stat
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			r, g, b, _ := src.At(x, y).RGBA()
+			lu.add(float64(r>>8+g>>8+b>>8) / 3)
+		}
+	}
+	return lu.mean() <= maxMean && lu.stddev() <= maxDev
+}
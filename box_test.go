@@ -0,0 +1,75 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTrimLetterbox(t *testing.T) {
+	r := image.Rect(-8, 4, 152, 124)
+	// noisyBlack fills b with the noise of compressed black.
+	rng := rand.New(rand.NewSource(17))
+	noisyBlack := func(img *image.RGBA, b image.Rectangle) {
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				v := uint8(8 + rng.Intn(12))
+				img.SetRGBA(x, y, color.RGBA{v, v, v, 255})
+			}
+		}
+	}
+	for _, tc := range []struct {
+		name   string
+		active image.Rectangle
+	}{
+		{"letterbox", image.Rect(-8, 19, 152, 109)},
+		{"pillarbox", image.Rect(12, 4, 132, 124)},
+		{"windowbox", image.Rect(2, 14, 142, 114)},
+		{"no bars", r},
+		{"all dark", image.Rectangle{}},
+	} {
+		src := image.NewRGBA(r)
+		noisyBlack(src, r)
+		if !tc.active.Empty() {
+			randSkinish(src.SubImage(tc.active).(*image.RGBA), rng)
+		}
+		want := tc.active
+		if want.Empty() {
+			want = r
+		}
+		if got := TrimLetterbox(src); got != want {
+			t.Errorf("%s: TrimLetterbox = %v, want %v", tc.name, got, want)
+		}
+	}
+}
+
+func TestMergeVertical(t *testing.T) {
+	side := image.Rect(60, 10, 80, 60)
+	face := image.Rect(10, 10, 50, 60)
+	neck := image.Rect(20, 64, 40, 90) // 4 rows below face
+	chest := image.Rect(0, 92, 60, 120)
+	for _, tc := range []struct {
+		name   string
+		boxes  []image.Rectangle
+		maxGap int
+		want   []image.Rectangle
+	}{
+		{"empty", nil, 4, nil},
+		{"gap within", []image.Rectangle{side, neck, face}, 4, []image.Rectangle{side, image.Rect(10, 10, 50, 90)}},
+		{"gap too wide", []image.Rectangle{face, neck}, 3, []image.Rectangle{face, neck}},
+		// Face and chest do not qualify until neck joins one.
+		{"repeated", []image.Rectangle{chest, face, neck}, 4, []image.Rectangle{image.Rect(0, 10, 60, 120)}},
+		{"overlapping", []image.Rectangle{face, face.Add(image.Pt(5, 20))}, 0, []image.Rectangle{image.Rect(10, 10, 55, 80)}},
+	} {
+		in := append([]image.Rectangle(nil), tc.boxes...)
+		got := MergeVertical(in, tc.maxGap)
+		if len(got) != len(tc.want) || len(got) != 0 && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: MergeVertical = %v, want %v", tc.name, got, tc.want)
+		}
+		if !reflect.DeepEqual(in, tc.boxes) {
+			t.Errorf("%s: input modified to %v", tc.name, in)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package face
+
+import (
+	"image"
+	"runtime"
+	"sync"
+)
+
+// ThresholdGrid lists the candidate values of each threshold tried
+// by Calibrate. An empty list tries only the default value.
+type ThresholdGrid struct {
+	MinR       []uint8
+	MinRGDelta []uint8
+	MaxRGDelta []uint8
+	MaxRGRatio []float64
+}
+
+// maxGrid caps the number of combinations Calibrate tries.
+const maxGrid = 4096
+
+// Calibrate searches grid for the ModeRGB thresholds that best
+// separate the positive images, which contain skin, from the
+// negative ones. The score of a combination is the mean coverage of
+// the positives less that of the negatives, in [-1, 1]; Calibrate
+// returns options holding the best thresholds, the first in grid
+// order on ties, and their score.
+//
+// It is an offline tool for tuning to a dataset, not for use per
+// request. The images are read once, and the combinations are
+// scored concurrently from a table of red-green counts, but a large
+// grid is still slow. A grid of more than 4096 combinations is
+// thinned by repeatedly dropping every other value from its longest
+// list.
+func Calibrate(positives, negatives []image.Image, grid ThresholdGrid) (Options, float64) {
+	var d [256 * 256]float64
+	weigh(&d, positives, 1)
+	weigh(&d, negatives, -1)
+
+	g := grid.thin()
+	ts := g.combinations()
+	scores := make([]float64, len(ts))
+	workers := runtime.GOMAXPROCS(0)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(ts); i += workers {
+				scores[i] = score(&d, ts[i])
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	best := 0
+	for i, s := range scores {
+		if s > scores[best] {
+			best = i
+		}
+	}
+	return Options{Thresholds: ts[best]}, scores[best]
+}
+
+// weigh adds to d, indexed by r<<8|g, the fraction of the pixels of
+// each image with those channels, times sign over len(imgs).
+func weigh(d *[256 * 256]float64, imgs []image.Image, sign float64) {
+	var h [256 * 256]int
+	for _, img := range imgs {
+		for i := range h {
+			h[i] = 0
+		}
+		r := img.Bounds()
+		if src, ok := img.(*image.RGBA); ok {
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				sp := src.PixOffset(r.Min.X, y)
+				for pix, ep := src.Pix, sp+r.Dx()*4; sp != ep; sp += 4 {
+					h[int(pix[sp])<<8|int(pix[sp+1])]++
+				}
+			}
+		} else {
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					r, g, _, _ := img.At(x, y).RGBA()
+					h[int(r>>8)<<8|int(g>>8)]++
+				}
+			}
+		}
+		n := r.Dx() * r.Dy()
+		if n == 0 {
+			continue
+		}
+		k := sign / float64(n*len(imgs))
+		for i, v := range h {
+			if v != 0 {
+				d[i] += k * float64(v)
+			}
+		}
+	}
+}
+
+// score sums the entries of d whose channels t accepts. The ModeRGB
+// test ignores blue, so this is the separation Calibrate maximizes.
+func score(d *[256 * 256]float64, t Thresholds) float64 {
+	num, den := t.ratio()
+	s := 0.0
+	for i, v := range d {
+		if v != 0 && t.band(uint8(i>>8), uint8(i), num, den) {
+			s += v
+		}
+	}
+	return s
+}
+
+// thin returns g with the defaults filled in and at most maxGrid
+// combinations.
+func (g ThresholdGrid) thin() ThresholdGrid {
+	if len(g.MinR) == 0 {
+		g.MinR = []uint8{defaultThresholds.MinR}
+	}
+	if len(g.MinRGDelta) == 0 {
+		g.MinRGDelta = []uint8{defaultThresholds.MinRGDelta}
+	}
+	if len(g.MaxRGDelta) == 0 {
+		g.MaxRGDelta = []uint8{defaultThresholds.MaxRGDelta}
+	}
+	if len(g.MaxRGRatio) == 0 {
+		g.MaxRGRatio = []float64{defaultThresholds.MaxRGRatio}
+	}
+	halve8 := func(v []uint8) []uint8 {
+		out := make([]uint8, 0, (len(v)+1)/2)
+		for i := 0; i < len(v); i += 2 {
+			out = append(out, v[i])
+		}
+		return out
+	}
+	for len(g.MinR)*len(g.MinRGDelta)*len(g.MaxRGDelta)*len(g.MaxRGRatio) > maxGrid {
+		switch n := len(g.MinR); {
+		case n >= len(g.MinRGDelta) && n >= len(g.MaxRGDelta) && n >= len(g.MaxRGRatio):
+			g.MinR = halve8(g.MinR)
+		case len(g.MinRGDelta) >= len(g.MaxRGDelta) && len(g.MinRGDelta) >= len(g.MaxRGRatio):
+			g.MinRGDelta = halve8(g.MinRGDelta)
+		case len(g.MaxRGDelta) >= len(g.MaxRGRatio):
+			g.MaxRGDelta = halve8(g.MaxRGDelta)
+		default:
+			v := g.MaxRGRatio
+			g.MaxRGRatio = make([]float64, 0, (len(v)+1)/2)
+			for i := 0; i < len(v); i += 2 {
+				g.MaxRGRatio = append(g.MaxRGRatio, v[i])
+			}
+		}
+	}
+	return g
+}
+
+// combinations returns the thresholds of g in grid order, varying
+// the last list fastest.
+func (g ThresholdGrid) combinations() []Thresholds {
+	var ts []Thresholds
+	for _, a := range g.MinR {
+		for _, b := range g.MinRGDelta {
+			for _, c := range g.MaxRGDelta {
+				for _, q := range g.MaxRGRatio {
+					ts = append(ts, Thresholds{a, b, c, q})
+				}
+			}
+		}
+	}
+	return ts
+}
+
+// CoverageDistribution returns a histogram of the SkinCoverage of
+// srcs over bins equal bins of [0, 1], the last closed, for choosing
+// a coverage cutoff that separates images with faces from those
+// without. The images are scanned concurrently. A bins less than 1
+// is treated as 1.
+func CoverageDistribution(srcs []image.Image, bins int) []int {
+	if bins < 1 {
+		bins = 1
+	}
+	cover := make([]float64, len(srcs))
+	workers := runtime.GOMAXPROCS(0)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(srcs); i += workers {
+				cover[i] = SkinCoverage(srcs[i])
+			}
+		}(w)
+	}
+	wg.Wait()
+	h := make([]int, bins)
+	for _, c := range cover {
+		i := int(c * float64(bins))
+		if i >= bins {
+			i = bins - 1
+		}
+		h[i]++
+	}
+	return h
+}
@@ -0,0 +1,92 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// halfSkin returns a 10×10 image whose left half is c and right half
+// is the background.
+func halfSkin(c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	paint(img, img.Rect, testBg)
+	paint(img, image.Rect(0, 0, 5, 10), c)
+	return img
+}
+
+func TestCalibrate(t *testing.T) {
+	// Pale skin with an r−g of 15, under the default band, against
+	// a beige wall with an r−g of 30, within it.
+	pale, wall := color.RGBA{120, 105, 90, 255}, color.RGBA{150, 120, 100, 255}
+	pos := []image.Image{halfSkin(pale), generic{halfSkin(pale)}}
+	neg := []image.Image{halfSkin(wall)}
+
+	grid := ThresholdGrid{MinRGDelta: []uint8{20, 10, 5}, MaxRGDelta: []uint8{90, 25, 20}}
+	opt, score := Calibrate(pos, neg, grid)
+	// (10, 25) separates them, as do (10, 20) and (5, 25) later in
+	// grid order.
+	want := defaultThresholds
+	want.MinRGDelta, want.MaxRGDelta = 10, 25
+	if opt.Thresholds != want || math.Abs(score-0.5) > 1e-9 {
+		t.Errorf("Calibrate = %+v, %v; want %+v, 0.5", opt.Thresholds, score, want)
+	}
+	if got := SkinCoverage(neg[0]); got != 0.5 {
+		t.Fatalf("default coverage of the wall %v, want 0.5", got)
+	}
+
+	// An empty grid tries only the defaults, which take the wall.
+	opt, score = Calibrate(pos, neg, ThresholdGrid{})
+	if opt.Thresholds != defaultThresholds || math.Abs(score+0.5) > 1e-9 {
+		t.Errorf("empty grid: %+v, %v; want the defaults, -0.5", opt.Thresholds, score)
+	}
+}
+
+func TestThinGrid(t *testing.T) {
+	v := make([]uint8, 100)
+	for i := range v {
+		v[i] = uint8(i)
+	}
+	g := ThresholdGrid{MinR: v, MinRGDelta: v, MaxRGDelta: v[:2]}.thin()
+	if n := len(g.MinR) * len(g.MinRGDelta) * len(g.MaxRGDelta) * len(g.MaxRGRatio); n > maxGrid {
+		t.Errorf("thinned to %d combinations, want at most %d", n, maxGrid)
+	}
+	if !reflect.DeepEqual(g.MaxRGDelta, v[:2]) || len(g.MaxRGRatio) != 1 {
+		t.Errorf("short lists thinned: %v, %v", g.MaxRGDelta, g.MaxRGRatio)
+	}
+}
+
+func TestCoverageDistribution(t *testing.T) {
+	r := image.Rect(0, 0, 20, 20)
+	solid := func(skin image.Rectangle) image.Image {
+		img := image.NewRGBA(r)
+		paint(img, r, testBg)
+		paint(img, skin, testSkin)
+		return img
+	}
+	srcs := []image.Image{
+		solid(image.Rectangle{}),                // 0
+		solid(image.Rect(0, 0, 20, 5)),          // 0.25
+		solid(image.Rect(0, 0, 20, 10)),         // 0.5
+		solid(r),                                // 1, in the last bin
+		solid(image.Rect(0, 0, 20, 19)),         // 0.95
+		generic{solid(image.Rect(0, 0, 20, 4))}, // 0.2
+	}
+	for _, tc := range []struct {
+		bins int
+		want []int
+	}{
+		{4, []int{2, 1, 1, 2}},
+		{0, []int{6}},
+		{-3, []int{6}},
+	} {
+		if got := CoverageDistribution(srcs, tc.bins); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%d bins: %v, want %v", tc.bins, got, tc.want)
+		}
+	}
+	if got := CoverageDistribution(nil, 3); !reflect.DeepEqual(got, []int{0, 0, 0}) {
+		t.Errorf("no images: %v, want [0 0 0]", got)
+	}
+}
@@ -0,0 +1,122 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"sort"
+)
+
+// SkinClusters groups the colors of src under the nonzero pixels
+// of mask into k clusters with k-means and returns their centers,
+// most populous first. Several well separated centers suggest
+// distinct subjects; a single tight cluster covering most of the
+// image suggests a skin-colored background.
+//
+// The result is deterministic: the centers are seeded with a fixed
+// source, on distinct colors, and refined for at most 32 iterations.
+// Fewer than k colors are returned if the mask covers fewer than k
+// distinct colors or a cluster ends up empty. Large masks are
+// subsampled to at most 65536 pixels.
+func SkinClusters(src image.Image, mask *image.Alpha, k int) []color.RGBA {
+	const (
+		maxIter   = 32
+		maxPoints = 1 << 16
+		seed      = 1
+	)
+	r := mask.Bounds().Intersect(src.Bounds())
+	var pts [][3]int32
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if mask.AlphaAt(x, y).A == 0 {
+				continue
+			}
+			r, g, b, _ := src.At(x, y).RGBA()
+			pts = append(pts, [3]int32{int32(r >> 8), int32(g >> 8), int32(b >> 8)})
+		}
+	}
+	if len(pts) > maxPoints {
+		step := (len(pts) + maxPoints - 1) / maxPoints
+		for i := range pts[:len(pts)/step] {
+			pts[i] = pts[i*step]
+		}
+		pts = pts[:len(pts)/step]
+	}
+	if k > len(pts) {
+		k = len(pts)
+	}
+	if k <= 0 {
+		return nil
+	}
+
+	// Two seeds on one color would split it and leave another
+	// merged with its neighbor.
+	rnd := rand.New(rand.NewSource(seed))
+	center := make([][3]int32, 0, k)
+	seen := make(map[[3]int32]bool)
+	for _, p := range rnd.Perm(len(pts)) {
+		if !seen[pts[p]] {
+			seen[pts[p]] = true
+			if center = append(center, pts[p]); len(center) == k {
+				break
+			}
+		}
+	}
+	k = len(center)
+	label := make([]int, len(pts))
+	sum := make([][3]int64, k)
+	size := make([]int, k)
+	for it := 0; it < maxIter; it++ {
+		moved := it == 0
+		for i, p := range pts {
+			c := nearest(center, p)
+			if c != label[i] {
+				label[i] = c
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+		for c := range sum {
+			sum[c], size[c] = [3]int64{}, 0
+		}
+		for i, p := range pts {
+			c := label[i]
+			sum[c][0] += int64(p[0])
+			sum[c][1] += int64(p[1])
+			sum[c][2] += int64(p[2])
+			size[c]++
+		}
+		for c := range center {
+			if n := int64(size[c]); n != 0 {
+				center[c] = [3]int32{int32(sum[c][0] / n), int32(sum[c][1] / n), int32(sum[c][2] / n)}
+			}
+		}
+	}
+
+	idx := make([]int, 0, k)
+	for c := range center {
+		if size[c] != 0 {
+			idx = append(idx, c)
+		}
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return size[idx[i]] > size[idx[j]] })
+	out := make([]color.RGBA, len(idx))
+	for i, c := range idx {
+		out[i] = color.RGBA{uint8(center[c][0]), uint8(center[c][1]), uint8(center[c][2]), 255}
+	}
+	return out
+}
+
+// nearest returns the index of the center closest to p.
+func nearest(center [][3]int32, p [3]int32) int {
+	best, bd := 0, int32(-1)
+	for i, c := range center {
+		dr, dg, db := p[0]-c[0], p[1]-c[1], p[2]-c[2]
+		if d := dr*dr + dg*dg + db*db; bd < 0 || d < bd {
+			best, bd = i, d
+		}
+	}
+	return best
+}
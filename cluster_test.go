@@ -0,0 +1,43 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+)
+
+func TestSkinClusters(t *testing.T) {
+	r := image.Rect(-10, 0, 90, 50)
+	src := image.NewRGBA(r)
+	light, mid, dark := color.RGBA{230, 190, 170, 255}, color.RGBA{198, 134, 102, 255}, color.RGBA{110, 70, 50, 255}
+	paint(src, image.Rect(-10, 0, 50, 50), light)
+	paint(src, image.Rect(50, 0, 80, 50), mid)
+	paint(src, image.Rect(80, 0, 90, 50), dark)
+	paint(src, image.Rect(-10, 40, 90, 50), testBg)
+	// The background is not under the mask.
+	mask := image.NewAlpha(r)
+	paint(mask, image.Rect(-10, 0, 90, 40), color.Alpha{255})
+
+	want := []color.RGBA{light, mid, dark}
+	got := SkinClusters(src, mask, 3)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SkinClusters = %v, want %v", got, want)
+	}
+	if again := SkinClusters(generic{src}, mask, 3); !reflect.DeepEqual(again, got) {
+		t.Errorf("second run = %v, want %v", again, got)
+	}
+
+	if got := SkinClusters(src, image.NewAlpha(r), 3); got != nil {
+		t.Errorf("empty mask: %v, want nil", got)
+	}
+	if got := SkinClusters(src, mask, 0); got != nil {
+		t.Errorf("k == 0: %v, want nil", got)
+	}
+	two := image.NewAlpha(r)
+	two.SetAlpha(0, 0, color.Alpha{255})
+	two.SetAlpha(85, 0, color.Alpha{255})
+	if got := SkinClusters(src, two, 5); !reflect.DeepEqual(got, []color.RGBA{light, dark}) && !reflect.DeepEqual(got, []color.RGBA{dark, light}) {
+		t.Errorf("two pixels, k == 5: %v, want their two colors", got)
+	}
+}
@@ -0,0 +1,257 @@
+package face
+
+import (
+	"image"
+	"unsafe"
+)
+
+// Detector detects skin in a stream of frames, such as those of a
+// video, reusing its mask between calls and smoothing the coverage
+// over recent frames. The zero value is ready to use.
+//
+// A Detector keeps state between calls to Detect, so it must not be
+// used by more than one goroutine at a time.
+type Detector struct {
+	// Options configures detection as in SkinMaskWith.
+	Options Options
+
+	// Smoothing is the weight, in (0, 1], of the newest coverage in
+	// CoverageSmoothed. Smaller values smooth more. Zero selects
+	// 0.25; values above 1 are treated as 1.
+	Smoothing float64
+
+	// SkipUnchanged, if positive, has ProcessFrames skip detection
+	// on a frame that differs by no more than SkipUnchanged, as in
+	// FrameChanged, from the last frame detected, and emit the
+	// summary of that frame again.
+	SkipUnchanged float64
+
+	mask *image.Alpha
+	ema  float64
+	n    int // frames folded into ema
+	s    scratch
+
+	grid  []uint8         // samples of the last frame processed
+	bound image.Rectangle // its bounds
+	last  Summary         // and its summary
+}
+
+// Detect returns the skin mask and coverage of src. The mask is
+// reused by the next call to Detect, so it must be copied to be
+// retained. A frame whose size differs from the last one resets the
+// smoothed coverage.
+func (d *Detector) Detect(src image.Image) (*image.Alpha, float64) {
+	r := src.Bounds()
+	if d.mask != nil && d.mask.Bounds().Size() != r.Size() {
+		d.n = 0
+	}
+	d.mask = d.alpha(r)
+	opt := d.Options
+	opt.AsConstraint = false
+	_, cover := skinMaskColor(src, d.mask, &opt)
+	d.fold(cover)
+	return d.mask, cover
+}
+
+// alpha returns a cleared mask with bounds r, reusing the pixels of
+// the last one if they are large enough.
+func (d *Detector) alpha(r image.Rectangle) *image.Alpha {
+	n := r.Dx() * r.Dy()
+	if d.mask == nil || cap(d.mask.Pix) < n {
+		return image.NewAlpha(r)
+	}
+	pix := d.mask.Pix[:n]
+	for i := range pix {
+		pix[i] = 0
+	}
+	*d.mask = image.Alpha{Pix: pix, Stride: r.Dx(), Rect: r}
+	return d.mask
+}
+
+// Components returns the connected regions of the mask of the last
+// frame passed to Detect, as the package-level Components does. Its
+// buffers are kept by d, so the result is valid until the next call
+// and a video loop at a fixed resolution does not allocate.
+func (d *Detector) Components(conn Connectivity) []Component {
+	if d.mask == nil {
+		return nil
+	}
+	_, cs := d.s.label(d.mask, conn)
+	return cs
+}
+
+// MemStats returns the number of bytes held by the buffers of d.
+func (d *Detector) MemStats() (bytes int) {
+	if d.mask != nil {
+		bytes += cap(d.mask.Pix)
+	}
+	bytes += cap(d.s.labels) * int(unsafe.Sizeof(int32(0)))
+	bytes += cap(d.s.stack) * int(unsafe.Sizeof(int(0)))
+	bytes += cap(d.s.cs) * int(unsafe.Sizeof(Component{}))
+	bytes += cap(d.grid)
+	return bytes
+}
+
+// Reset releases the buffers of d and clears its smoothed coverage.
+// The Options and Smoothing are kept.
+func (d *Detector) Reset() {
+	d.mask, d.s = nil, scratch{}
+	d.ema, d.n = 0, 0
+	d.grid, d.bound, d.last = nil, image.Rectangle{}, Summary{}
+}
+
+// ProcessFrames runs a video loop: it pulls frames from next until
+// next reports false, detects skin in each as Detect does, and passes
+// the Summary of each to onResult, reusing the buffers of d across
+// frames. Next and onResult are called in turn on the caller's
+// goroutine, so the frame returned by next may be reused once
+// onResult returns. The Coverage of a summary is that of the frame,
+// not CoverageSmoothed.
+func (d *Detector) ProcessFrames(next func() (image.Image, bool), onResult func(Summary)) {
+	var grid []uint8
+	for {
+		src, ok := next()
+		if !ok {
+			return
+		}
+		r := src.Bounds()
+		if d.SkipUnchanged > 0 {
+			grid = sampleGrid(src, grid)
+			if d.grid != nil && r == d.bound && gridDiff(d.grid, grid) <= d.SkipUnchanged {
+				onResult(d.last)
+				continue
+			}
+			d.grid, grid = grid, d.grid
+		}
+		_, cover := d.Detect(src)
+		d.bound, d.last = r, summarize(src, cover, d.Components(Conn8))
+		onResult(d.last)
+	}
+}
+
+// ProcessFrames is the ProcessFrames method of a zero Detector.
+func ProcessFrames(next func() (image.Image, bool), onResult func(Summary)) {
+	var d Detector
+	d.ProcessFrames(next, onResult)
+}
+
+// CoverageSmoothed returns the exponential moving average of the
+// coverage of the frames passed to Detect since the last change of
+// frame size, or 0 if there were none.
+func (d *Detector) CoverageSmoothed() float64 {
+	return d.ema
+}
+
+func (d *Detector) fold(cover float64) {
+	a := d.Smoothing
+	switch {
+	case a <= 0:
+		a = 0.25
+	case a > 1:
+		a = 1
+	}
+	if d.n == 0 {
+		d.ema = cover
+	} else {
+		d.ema += a * (cover - d.ema)
+	}
+	d.n++
+}
+
+// FrameChanged reports whether cur differs from prev by more than
+// threshold, so that detection can be skipped on repeated frames.
+// The difference is the mean absolute difference of the r, g and b
+// channels, in [0, 255], over a sparse grid of at most 64×64
+// samples. Frames of differing bounds have always changed.
+func FrameChanged(prev, cur image.Image, threshold float64) bool {
+	r := cur.Bounds()
+	if prev.Bounds() != r {
+		return true
+	}
+	if r.Empty() {
+		return false
+	}
+	return gridDiff(sampleGrid(prev, nil), sampleGrid(cur, nil)) > threshold
+}
+
+// sampleGrid appends to buf[:0] the r, g and b of the pixels of src
+// on the grid of FrameChanged.
+func sampleGrid(src image.Image, buf []uint8) []uint8 {
+	const (
+		grid = 64
+	)
+	r := src.Bounds()
+	buf = buf[:0]
+	if r.Empty() {
+		return buf
+	}
+	dx := (r.Dx() + grid - 1) / grid
+	dy := (r.Dy() + grid - 1) / grid
+	rgba, ok := src.(*image.RGBA)
+	for y := r.Min.Y; y < r.Max.Y; y += dy {
+		for x := r.Min.X; x < r.Max.X; x += dx {
+			if ok {
+				i := rgba.PixOffset(x, y)
+				buf = append(buf, rgba.Pix[i:i+3]...)
+			} else {
+				cr, cg, cb, _ := src.At(x, y).RGBA()
+				pr, pg, pb := to8(cr, cg, cb)
+				buf = append(buf, pr, pg, pb)
+			}
+		}
+	}
+	return buf
+}
+
+// gridDiff returns the mean absolute difference of the samples of
+// two grids of the same bounds.
+func gridDiff(a, b []uint8) float64 {
+	sum := 0
+	for i := range a {
+		sum += absDiff(a[i], b[i])
+	}
+	return float64(sum) / float64(len(a))
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// DetectBurst detects skin in each of a burst of frames and returns
+// the mask of pixels that are skin in a majority of them, with its
+// coverage, which is more robust than any single frame. The frames
+// are assumed aligned: they must have the same size, and pixels are
+// matched by their offset from each frame's Min; otherwise
+// DetectBurst panics. The mask has the bounds of the first frame.
+func DetectBurst(frames []image.Image) (*image.Alpha, float64) {
+	if len(frames) == 0 {
+		return image.NewAlpha(image.Rectangle{}), 0
+	}
+	r := frames[0].Bounds()
+	votes := make([]uint16, r.Dx()*r.Dy())
+	for _, f := range frames {
+		b := f.Bounds()
+		if b.Size() != r.Size() {
+			panic("face: DetectBurst: frames differ in size")
+		}
+		m, _ := skinMaskColor(f, nil, &Options{})
+		a := m.(*image.Alpha)
+		for i, v := range a.Pix {
+			if v != 0 {
+				votes[i]++
+			}
+		}
+	}
+	mask := image.NewAlpha(r)
+	n := 0
+	for i, v := range votes {
+		if 2*int(v) > len(frames) {
+			mask.Pix[i] = 255
+			n++
+		}
+	}
+	return mask, coverage(n, len(votes))
+}
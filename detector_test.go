@@ -0,0 +1,41 @@
+package face
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFrameChanged(t *testing.T) {
+	r := image.Rect(-3, 2, 197, 102)
+	prev := randRGBA(r, 20)
+	// The same frame with its red raised by 9, a mean difference of
+	// 3 over r, g and b.
+	cur := image.NewRGBA(r)
+	for i := 0; i < len(prev.Pix); i += 4 {
+		if prev.Pix[i] > 246 {
+			prev.Pix[i] = 246
+		}
+	}
+	copy(cur.Pix, prev.Pix)
+	for i := 0; i < len(cur.Pix); i += 4 {
+		cur.Pix[i] += 9
+	}
+	for _, tc := range []struct {
+		name      string
+		prev, cur image.Image
+		threshold float64
+		want      bool
+	}{
+		{"repeated", prev, prev, 0, false},
+		{"repeated through At", prev, generic{prev}, 0, false},
+		{"changed", prev, cur, 2.9, true},
+		{"changed through At", generic{prev}, generic{cur}, 2.9, true},
+		{"under threshold", prev, cur, 3.1, false},
+		{"resized", prev, prev.SubImage(r.Inset(1)), 255, true},
+		{"empty", image.NewRGBA(image.Rectangle{}), image.NewRGBA(image.Rectangle{}), 0, false},
+	} {
+		if got := FrameChanged(tc.prev, tc.cur, tc.threshold); got != tc.want {
+			t.Errorf("%s: FrameChanged = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
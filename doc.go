@@ -0,0 +1,18 @@
+// Package face detects skin in images by color.
+//
+// Functions documented as working in place, such as StretchSkin,
+// FillHoles, FloodFill and SkinMaskRegionInto, modify their
+// arguments, and the SkinMask functions write to the mask they are
+// given. Otherwise the functions in this package neither retain nor
+// modify their arguments, and they keep no mutable global state of
+// their own. So they may be called from many goroutines at once,
+// including on the same source image, as long as each call writes to
+// its own mask and no call modifies an image another is reading.
+// Detector and TileDetector keep state between calls and are not
+// safe for concurrent use. Package-wide settings such as
+// SetAutoSelect are guarded and may be changed at any time.
+//
+// Detection is deterministic. The same source and options always
+// produce the same mask and exactly the same coverage, whether the
+// work is done serially or by SkinMaskN.
+package face
@@ -0,0 +1,68 @@
+package face
+
+import (
+	"bytes"
+	"image"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentUse runs detection from many goroutines on one shared
+// source, each with its own mask, while the auto-selection heuristic
+// is swapped. Run it with -race.
+func TestConcurrentUse(t *testing.T) {
+	const (
+		workers = 8
+		rounds  = 10
+	)
+	src := randRGBA(image.Rect(0, 0, 128, 96), 3)
+	ref, cover := SkinMask(src, nil)
+	content := Content(src, src.Bounds())
+
+	done := make(chan struct{})
+	var sel sync.WaitGroup
+	sel.Add(1)
+	go func() {
+		defer sel.Done()
+		ycc := func(image.Image) Mode { return ModeYCbCr }
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				SetAutoSelect(nil)
+				return
+			default:
+			}
+			if i%2 == 0 {
+				SetAutoSelect(ycc)
+			} else {
+				SetAutoSelect(nil)
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mask := image.NewAlpha(src.Bounds())
+			for i := 0; i < rounds; i++ {
+				for j := range mask.Pix {
+					mask.Pix[j] = 0
+				}
+				if _, c := SkinMask(src, mask); c != cover || !bytes.Equal(mask.Pix, ref.(*image.Alpha).Pix) {
+					t.Errorf("SkinMask differs under concurrency: coverage %v, want %v", c, cover)
+					return
+				}
+				if c := Content(src, src.Bounds()); c != content {
+					t.Errorf("Content = %d under concurrency, want %d", c, content)
+					return
+				}
+				SkinMaskAuto(src, nil)
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	sel.Wait()
+}
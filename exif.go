@@ -0,0 +1,189 @@
+package face
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+var (
+	errNotJPEG = errors.New("face: not a JPEG")
+	errEXIF    = errors.New("face: malformed EXIF")
+)
+
+// OptionsFromEXIF reads the EXIF metadata of the JPEG image in r and
+// returns options whose ModeRGB thresholds compensate for the color
+// cast of the light it was taken under. EXIF does not record a
+// color temperature, so the LightSource tag is mapped to one: 3200K
+// for tungsten, 4000K for fluorescent, 5500K for daylight and flash,
+// 6500K for cloudy weather and 7500K for shade, or that of the named
+// standard illuminant. Warm light raises r−g, so both bounds of the
+// r−g band are shifted by
+//
+//	(5500 − K) / 250
+//
+// clamped to ±12. Only the metadata is read, not the image data. If
+// the image has no EXIF or no LightSource tag, or the light source
+// is unknown, the zero Options are returned.
+func OptionsFromEXIF(r io.Reader) (Options, error) {
+	tiff, err := readEXIF(bufio.NewReader(r))
+	if err != nil || tiff == nil {
+		return Options{}, err
+	}
+	src, ok, err := lightSource(tiff)
+	if err != nil || !ok {
+		return Options{}, err
+	}
+	k, ok := kelvin[src]
+	if !ok {
+		return Options{}, nil
+	}
+	return Options{Thresholds: defaultThresholds.shift((5500 - k) / 250)}, nil
+}
+
+// kelvin maps EXIF LightSource values to color temperatures.
+var kelvin = map[uint16]int{
+	1:  5500, // daylight
+	2:  4000, // fluorescent
+	3:  3200, // tungsten
+	4:  5500, // flash
+	9:  5500, // fine weather
+	10: 6500, // cloudy
+	11: 7500, // shade
+	12: 6400, // daylight fluorescent
+	13: 5000, // day white fluorescent
+	14: 4200, // cool white fluorescent
+	15: 3500, // white fluorescent
+	16: 3000, // warm white fluorescent
+	17: 2856, // standard light A
+	18: 4874, // standard light B
+	19: 6774, // standard light C
+	20: 5500, // D55
+	21: 6500, // D65
+	22: 7500, // D75
+	23: 5000, // D50
+	24: 3200, // ISO studio tungsten
+}
+
+// shift moves both bounds of the r−g band of t by d, clamped to
+// ±12 and to [0, 255].
+func (t Thresholds) shift(d int) Thresholds {
+	const (
+		maxShift = 12
+	)
+	if d > maxShift {
+		d = maxShift
+	} else if d < -maxShift {
+		d = -maxShift
+	}
+	add := func(v uint8) uint8 {
+		switch v := int(v) + d; {
+		case v < 0:
+			return 0
+		case v > 255:
+			return 255
+		default:
+			return uint8(v)
+		}
+	}
+	t.MinRGDelta, t.MaxRGDelta = add(t.MinRGDelta), add(t.MaxRGDelta)
+	return t
+}
+
+// readEXIF returns the TIFF structure of the EXIF segment of the
+// JPEG in r, or nil if there is none before the image data.
+func readEXIF(r *bufio.Reader) ([]byte, error) {
+	var b [4]byte
+	if _, err := io.ReadFull(r, b[:2]); err != nil || b[0] != 0xff || b[1] != 0xd8 {
+		return nil, errNotJPEG
+	}
+	for {
+		if _, err := io.ReadFull(r, b[:2]); err != nil {
+			return nil, err
+		}
+		if b[0] != 0xff {
+			return nil, errNotJPEG
+		}
+		switch marker := b[1]; {
+		case marker == 0xff:
+			r.UnreadByte() // fill byte
+			continue
+		case marker == 0xd9 || marker == 0xda:
+			return nil, nil // EOI or SOS: no EXIF
+		case marker == 0x01 || marker >= 0xd0 && marker <= 0xd7:
+			continue // no length
+		}
+		if _, err := io.ReadFull(r, b[2:4]); err != nil {
+			return nil, err
+		}
+		n := int(binary.BigEndian.Uint16(b[2:4])) - 2
+		if n < 0 {
+			return nil, errNotJPEG
+		}
+		seg := make([]byte, n)
+		if _, err := io.ReadFull(r, seg); err != nil {
+			return nil, err
+		}
+		if b[1] == 0xe1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return seg[6:], nil
+		}
+	}
+}
+
+// lightSource returns the LightSource tag of the EXIF IFD of tiff.
+func lightSource(tiff []byte) (v uint16, ok bool, err error) {
+	const (
+		tagExifIFD     = 0x8769
+		tagLightSource = 0x9208
+	)
+	if len(tiff) < 8 {
+		return 0, false, errEXIF
+	}
+	var bo binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 0, false, errEXIF
+	}
+	if bo.Uint16(tiff[2:]) != 42 {
+		return 0, false, errEXIF
+	}
+	off, ok, err := ifdValue(tiff, bo, bo.Uint32(tiff[4:]), tagExifIFD)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	v32, ok, err := ifdValue(tiff, bo, off, tagLightSource)
+	return uint16(v32), ok, err
+}
+
+// ifdValue returns the inline value of the tag in the IFD at off.
+// A SHORT value is returned in the low bits.
+func ifdValue(tiff []byte, bo binary.ByteOrder, off uint32, tag uint16) (v uint32, ok bool, err error) {
+	const (
+		typeShort = 3
+	)
+	if uint64(off)+2 > uint64(len(tiff)) {
+		return 0, false, errEXIF
+	}
+	n := int(bo.Uint16(tiff[off:]))
+	p := int(off) + 2
+	if p+12*n > len(tiff) {
+		return 0, false, errEXIF
+	}
+	for i := 0; i < n; i, p = i+1, p+12 {
+		e := tiff[p : p+12]
+		if bo.Uint16(e) != tag {
+			continue
+		}
+		if bo.Uint16(e[2:]) == typeShort {
+			return uint32(bo.Uint16(e[8:])), true, nil
+		}
+		return bo.Uint32(e[8:]), true, nil
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,133 @@
+package face
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// ifdEntry is a TIFF IFD entry with a count of 1 and an inline value.
+type ifdEntry struct {
+	tag, typ uint16
+	value    uint32
+}
+
+// byteOrder is a byte order that can also append, as those of
+// package binary do.
+type byteOrder interface {
+	binary.ByteOrder
+	binary.AppendByteOrder
+}
+
+// tiffEXIF returns a TIFF structure in byte order bo whose IFD0
+// holds ifd0 and, if exif is not nil, an Exif IFD pointer to an IFD
+// holding exif. A SHORT value is stored in the first two bytes of
+// the value field, as TIFF requires, with junk in the other two.
+func tiffEXIF(bo byteOrder, ifd0, exif []ifdEntry) []byte {
+	var b []byte
+	if bo == byteOrder(binary.LittleEndian) {
+		b = append(b, "II"...)
+	} else {
+		b = append(b, "MM"...)
+	}
+	b = bo.AppendUint16(b, 42)
+	b = bo.AppendUint32(b, 8)
+	if exif != nil {
+		off := 8 + 2 + 12*(len(ifd0)+1) + 4
+		ifd0 = append(ifd0, ifdEntry{0x8769, 4, uint32(off)})
+	}
+	for _, ifd := range [][]ifdEntry{ifd0, exif} {
+		if ifd == nil {
+			continue
+		}
+		b = bo.AppendUint16(b, uint16(len(ifd)))
+		for _, e := range ifd {
+			b = bo.AppendUint16(b, e.tag)
+			b = bo.AppendUint16(b, e.typ)
+			b = bo.AppendUint32(b, 1)
+			if e.typ == 3 {
+				b = bo.AppendUint16(b, uint16(e.value))
+				b = append(b, 0xab, 0xcd)
+			} else {
+				b = bo.AppendUint32(b, e.value)
+			}
+		}
+		b = bo.AppendUint32(b, 0) // no next IFD
+	}
+	return b
+}
+
+// jpegEXIF returns the start of a JPEG whose APP1 segment holds tiff,
+// after an APP0 segment and before the start of scan.
+func jpegEXIF(tiff []byte) []byte {
+	b := []byte{0xff, 0xd8}
+	b = append(b, 0xff, 0xe0, 0, 7, 'J', 'F', 'I', 'F', 0)
+	if tiff != nil {
+		seg := append([]byte("Exif\x00\x00"), tiff...)
+		b = append(b, 0xff, 0xe1)
+		b = binary.BigEndian.AppendUint16(b, uint16(len(seg)+2))
+		b = append(b, seg...)
+	}
+	return append(b, 0xff, 0xda, 0, 2)
+}
+
+// lightEXIF returns a JPEG recording the light source v as a value
+// of type typ in byte order bo.
+func lightEXIF(bo byteOrder, typ uint16, v uint32) []byte {
+	return jpegEXIF(tiffEXIF(bo, []ifdEntry{{0x010f, 2, 0}}, []ifdEntry{{0x9208, typ, v}}))
+}
+
+func TestOptionsFromEXIF(t *testing.T) {
+	le, be := byteOrder(binary.LittleEndian), byteOrder(binary.BigEndian)
+	band := func(lo, hi uint8) Options {
+		th := defaultThresholds
+		th.MinRGDelta, th.MaxRGDelta = lo, hi
+		return Options{Thresholds: th}
+	}
+	truncated := tiffEXIF(le, nil, []ifdEntry{{0x9208, 3, 3}, {0x9209, 3, 0}})
+	truncated = truncated[:len(truncated)-12]
+	pastEnd := tiffEXIF(be, []ifdEntry{{0x8769, 4, 1 << 20}}, nil)
+	badMagic := tiffEXIF(le, nil, []ifdEntry{{0x9208, 3, 3}})
+	badMagic[2] = 43
+	badOrder := tiffEXIF(le, nil, []ifdEntry{{0x9208, 3, 3}})
+	copy(badOrder, "IM")
+
+	for _, tt := range []struct {
+		name string
+		jpeg []byte
+		want Options
+		err  error
+	}{
+		{"II SHORT tungsten", lightEXIF(le, 3, 3), band(29, 99), nil},
+		{"MM SHORT tungsten", lightEXIF(be, 3, 3), band(29, 99), nil},
+		{"II LONG cloudy", lightEXIF(le, 4, 10), band(16, 86), nil},
+		{"MM LONG shade", lightEXIF(be, 4, 11), band(12, 82), nil},
+		{"MM SHORT standard light A", lightEXIF(be, 3, 17), band(30, 100), nil},
+		{"daylight", lightEXIF(le, 3, 1), band(20, 90), nil},
+		{"unknown light source", lightEXIF(le, 3, 255), Options{}, nil},
+		{"no LightSource tag", jpegEXIF(tiffEXIF(le, nil, []ifdEntry{{0x9209, 3, 0}})), Options{}, nil},
+		{"no Exif IFD", jpegEXIF(tiffEXIF(be, []ifdEntry{{0x010f, 2, 0}}, nil)), Options{}, nil},
+		{"no EXIF", jpegEXIF(nil), Options{}, nil},
+		{"truncated IFD", jpegEXIF(truncated), Options{}, errEXIF},
+		{"offset past the end", jpegEXIF(pastEnd), Options{}, errEXIF},
+		{"short TIFF header", jpegEXIF([]byte("II*\x00")), Options{}, errEXIF},
+		{"bad magic", jpegEXIF(badMagic), Options{}, errEXIF},
+		{"bad byte order", jpegEXIF(badOrder), Options{}, errEXIF},
+		{"not a JPEG", []byte("\x89PNG\r\n\x1a\n"), Options{}, errNotJPEG},
+		{"truncated segment", lightEXIF(le, 3, 3)[:30], Options{}, io.ErrUnexpectedEOF},
+	} {
+		got, err := OptionsFromEXIF(bytes.NewReader(tt.jpeg))
+		if err != tt.err || got.Thresholds != tt.want.Thresholds {
+			t.Errorf("%s: %+v, %v; want %+v, %v", tt.name, got.Thresholds, err, tt.want.Thresholds, tt.err)
+		}
+	}
+
+	// No light source shifts by more than 12, but shift clamps.
+	if got := defaultThresholds.shift(40); got != band(32, 102).Thresholds {
+		t.Errorf("shift(40) = %+v, want the band shifted by 12", got)
+	}
+	if got := (Thresholds{MinRGDelta: 5, MaxRGDelta: 250}).shift(-12).shift(12).shift(12); got.MinRGDelta != 24 || got.MaxRGDelta != 255 {
+		t.Errorf("shifts clamped to [0, 255]: %+v", got)
+	}
+}
@@ -0,0 +1,233 @@
+package face
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// randSkinish fills the pixels of img within its bounds with random
+// colors, half of them drawn near skin tones so that masks are
+// neither empty nor full.
+func randSkinish(img draw.Image, rng *rand.Rand) {
+	r := img.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255}
+			if rng.Intn(2) == 0 {
+				c = color.RGBA{uint8(150 + rng.Intn(100)), uint8(90 + rng.Intn(70)), uint8(60 + rng.Intn(70)), 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+}
+
+// randRGBA returns an *image.RGBA of bounds r filled by randSkinish.
+func randRGBA(r image.Rectangle, seed int64) *image.RGBA {
+	img := image.NewRGBA(r)
+	randSkinish(img, rand.New(rand.NewSource(seed)))
+	return img
+}
+
+// randYCbCr returns an *image.YCbCr of bounds r and subsampling ratio
+// with random planes.
+func randYCbCr(r image.Rectangle, ratio image.YCbCrSubsampleRatio, seed int64) *image.YCbCr {
+	img := image.NewYCbCr(r, ratio)
+	rng := rand.New(rand.NewSource(seed))
+	rng.Read(img.Y)
+	for i := range img.Cb {
+		img.Cb[i] = uint8(80 + rng.Intn(60))
+		img.Cr[i] = uint8(125 + rng.Intn(60))
+	}
+	return img
+}
+
+// generic hides the concrete type of an image, forcing the paths
+// that read it through At.
+type generic struct{ image.Image }
+
+// atRGBA returns a copy of src as an *image.RGBA, converting each
+// pixel through At as the generic paths read it.
+func atRGBA(src image.Image) *image.RGBA {
+	r := src.Bounds()
+	dst := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dst.Set(x, y, src.At(x, y))
+		}
+	}
+	return dst
+}
+
+// refMask reports, for each pixel of r in row-major order, whether
+// IsSkin accepts the color src.At returns for it.
+func refMask(src image.Image, r image.Rectangle) (set []bool, n int) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cr, cg, cb, _ := src.At(x, y).RGBA()
+			ok := IsSkin(uint8(cr>>8), uint8(cg>>8), uint8(cb>>8))
+			set = append(set, ok)
+			if ok {
+				n++
+			}
+		}
+	}
+	return set, n
+}
+
+// isSet reports whether the mask pixel at x, y is opaque white, as
+// SkinMask writes for skin in every mask type.
+func isSet(mask image.Image, x, y int) bool {
+	r, g, b, a := mask.At(x, y).RGBA()
+	return r == 0xffff && g == 0xffff && b == 0xffff && a == 0xffff
+}
+
+// countSet returns the numbers of pixels set in mask where in is
+// true and where it is false.
+func countSet(mask image.Image, in func(x, y int) bool) (inside, outside int) {
+	r := mask.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			switch {
+			case !isSet(mask, x, y):
+			case in(x, y):
+				inside++
+			default:
+				outside++
+			}
+		}
+	}
+	return inside, outside
+}
+
+// checkMask fails t unless mask holds exactly the pixels of want, in
+// row-major order over its bounds.
+func checkMask(t *testing.T, mask image.Image, want []bool) {
+	t.Helper()
+	r := mask.Bounds()
+	i := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x, i = x+1, i+1 {
+			if got := isSet(mask, x, y); got != want[i] {
+				t.Fatalf("mask at (%d, %d) = %v, want %v", x, y, got, want[i])
+			}
+		}
+	}
+}
+
+func fuzzSource(kind uint8, r image.Rectangle, seed int64) image.Image {
+	rng := rand.New(rand.NewSource(seed))
+	switch kind % 5 {
+	case 1:
+		img := image.NewNRGBA(r)
+		randSkinish(img, rng)
+		for i := 3; i < len(img.Pix); i += 4 {
+			img.Pix[i] = uint8(rng.Intn(256))
+		}
+		return img
+	case 2:
+		img := image.NewGray(r)
+		rng.Read(img.Pix)
+		return img
+	case 3:
+		img := image.NewCMYK(r)
+		randSkinish(img, rng)
+		return img
+	case 4:
+		ratios := []image.YCbCrSubsampleRatio{
+			image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio422,
+			image.YCbCrSubsampleRatio420, image.YCbCrSubsampleRatio440,
+			image.YCbCrSubsampleRatio411, image.YCbCrSubsampleRatio410,
+		}
+		ratio := ratios[rng.Intn(len(ratios))]
+		if r.Min.X < 0 || r.Min.Y < 0 {
+			// COffset divides negative coordinates toward zero,
+			// so At itself fails on subsampled planes there.
+			ratio = image.YCbCrSubsampleRatio444
+		}
+		return randYCbCr(r, ratio, seed)
+	}
+	img := image.NewRGBA(r)
+	randSkinish(img, rng)
+	return img
+}
+
+func fuzzMask(kind uint8, r image.Rectangle) draw.Image {
+	switch kind % 4 {
+	case 1:
+		return image.NewAlpha16(r)
+	case 2:
+		return image.NewGray(r)
+	case 3:
+		return image.NewRGBA(r)
+	}
+	return image.NewAlpha(r)
+}
+
+// FuzzSkinMaskParity checks the fast paths of SkinMask, for every
+// standard source and mask type, against classifying each pixel as
+// read through At, on sub-images and masks of arbitrary bounds.
+func FuzzSkinMaskParity(f *testing.F) {
+	f.Add(int64(1), uint8(0), uint8(0), int8(0), int8(0), uint8(20), uint8(20), int8(2), int8(2), int8(-2), int8(-2))
+	f.Add(int64(2), uint8(1), uint8(1), int8(-5), int8(-5), uint8(15), uint8(15), int8(0), int8(0), int8(0), int8(0))
+	f.Add(int64(3), uint8(2), uint8(2), int8(3), int8(-7), uint8(9), uint8(30), int8(1), int8(3), int8(4), int8(-1))
+	f.Add(int64(4), uint8(3), uint8(3), int8(-3), int8(-3), uint8(8), uint8(8), int8(-2), int8(-2), int8(2), int8(2))
+	f.Add(int64(5), uint8(4), uint8(0), int8(7), int8(1), uint8(33), uint8(17), int8(3), int8(1), int8(-5), int8(0))
+	f.Add(int64(6), uint8(4), uint8(1), int8(0), int8(0), uint8(0), uint8(12), int8(0), int8(0), int8(0), int8(0))
+	f.Fuzz(func(t *testing.T, seed int64, sk, mk uint8, x0, y0 int8, w, h uint8, l, tp, rt, bt int8) {
+		whole := image.Rect(int(x0), int(y0), int(x0)+int(w%48), int(y0)+int(h%48))
+		src := fuzzSource(sk, whole, seed)
+
+		// A sub-image of src, and a mask shifted and resized
+		// relative to it, possibly reaching outside.
+		sub := image.Rect(whole.Min.X+int(l%8), whole.Min.Y+int(tp%8), whole.Max.X-int(rt%8), whole.Max.Y-int(bt%8))
+		if s, ok := src.(interface {
+			SubImage(image.Rectangle) image.Image
+		}); ok {
+			src = s.SubImage(sub)
+		}
+		mr := src.Bounds()
+		if mk&4 != 0 {
+			mr = image.Rect(mr.Min.X+int(l%3), mr.Min.Y-int(tp%3), mr.Max.X+int(rt%3), mr.Max.Y-int(bt%3))
+		}
+		if mr.Empty() {
+			mr = image.Rectangle{}
+		}
+
+		want, n := refMask(src, mr)
+		mask, cover := SkinMask(src, fuzzMask(mk, mr))
+		checkMask(t, mask, want)
+		if wc := coverage(n, mr.Dx()*mr.Dy()); cover != wc {
+			t.Fatalf("coverage %v, want %v", cover, wc)
+		}
+	})
+}
+
+// FuzzOptionsFromEXIF checks that OptionsFromEXIF, on arbitrary
+// input, neither panics nor returns anything but the zero Options or
+// the default band shifted by at most 12.
+func FuzzOptionsFromEXIF(f *testing.F) {
+	le, be := byteOrder(binary.LittleEndian), byteOrder(binary.BigEndian)
+	f.Add(lightEXIF(le, 3, 3))
+	f.Add(lightEXIF(be, 4, 11))
+	f.Add(jpegEXIF(tiffEXIF(le, []ifdEntry{{0x8769, 4, 1 << 20}}, nil)))
+	f.Add(jpegEXIF(nil))
+	f.Add([]byte{0xff, 0xd8, 0xff, 0xff, 0xff, 0xe1, 0, 2})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		opt, err := OptionsFromEXIF(bytes.NewReader(b))
+		if err != nil || opt.Thresholds == (Thresholds{}) {
+			if opt.Thresholds != (Thresholds{}) {
+				t.Fatalf("thresholds %+v with error %v", opt.Thresholds, err)
+			}
+			return
+		}
+		d := int(opt.Thresholds.MinRGDelta) - int(defaultThresholds.MinRGDelta)
+		if d < -12 || d > 12 || opt.Thresholds != defaultThresholds.shift(d) {
+			t.Fatalf("thresholds %+v, not the default band shifted", opt.Thresholds)
+		}
+	})
+}
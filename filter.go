@@ -0,0 +1,281 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// BoxBlur returns src blurred by a (2*radius+1)² box filter. Each
+// pass keeps a running sum, so the cost per pixel does not depend
+// on radius. Near the edges the window shrinks to the pixels inside
+// the image. A radius less than 1 returns an unblurred copy.
+func BoxBlur(src image.Image, radius int) *image.RGBA {
+	r := src.Bounds()
+	dst := image.NewRGBA(r)
+	draw.Draw(dst, r, src, r.Min, draw.Src)
+	if radius < 1 {
+		return dst
+	}
+	boxBlur(dst.Pix, dst.Stride, r.Dx(), r.Dy(), 4, radius)
+	return dst
+}
+
+// Feather returns a soft copy of mask for compositing. The mask is
+// made binary, nonzero pixels becoming 255, and box blurred with the
+// given radius, so edges ramp smoothly over about 2*radius+1 pixels.
+// A radius less than 1 returns the binary copy.
+func Feather(mask *image.Alpha, radius int) *image.Alpha {
+	r := mask.Bounds()
+	dst := image.NewAlpha(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		src := mask.Pix[mask.PixOffset(r.Min.X, y):]
+		row := dst.Pix[dst.PixOffset(r.Min.X, y):]
+		for x := range row[:r.Dx()] {
+			if src[x] != 0 {
+				row[x] = 255
+			}
+		}
+	}
+	if radius < 1 {
+		return dst
+	}
+	boxBlur(dst.Pix, dst.Stride, r.Dx(), r.Dy(), 1, radius)
+	return dst
+}
+
+// boxBlur blurs, in place, the w×h samples with c interleaved
+// channels per pixel, stride bytes apart, with a horizontal then a
+// vertical box of the given radius.
+func boxBlur(pix []uint8, stride, w, h, c, radius int) {
+	n := w
+	if h > n {
+		n = h
+	}
+	line, out := make([]uint8, n*c), make([]uint8, n*c)
+	sum := make([]int, c)
+
+	// blur1 blurs the first n pixels of line into out.
+	blur1 := func(n int) []uint8 {
+		for k := range sum {
+			sum[k] = 0
+		}
+		lo, hi := 0, 0 // window [lo, hi)
+		for i := 0; i < n; i++ {
+			for ; hi < n && hi <= i+radius; hi++ {
+				for k := 0; k < c; k++ {
+					sum[k] += int(line[hi*c+k])
+				}
+			}
+			for ; lo < i-radius; lo++ {
+				for k := 0; k < c; k++ {
+					sum[k] -= int(line[lo*c+k])
+				}
+			}
+			d := hi - lo
+			for k := 0; k < c; k++ {
+				out[i*c+k] = uint8((sum[k] + d/2) / d)
+			}
+		}
+		return out[:n*c]
+	}
+
+	for y := 0; y < h; y++ {
+		row := pix[y*stride : y*stride+w*c]
+		copy(line, row)
+		copy(row, blur1(w))
+	}
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			copy(line[y*c:y*c+c], pix[y*stride+x*c:])
+		}
+		blur1(h)
+		for y := 0; y < h; y++ {
+			copy(pix[y*stride+x*c:y*stride+x*c+c], out[y*c:])
+		}
+	}
+}
+
+// toRGBA returns src as an *image.RGBA, copying it if necessary.
+func toRGBA(src image.Image) *image.RGBA {
+	if src, ok := src.(*image.RGBA); ok {
+		return src
+	}
+	r := src.Bounds()
+	dst := image.NewRGBA(r)
+	draw.Draw(dst, r, src, r.Min, draw.Src)
+	return dst
+}
+
+// downscale halves src in each dimension by averaging 2×2 blocks.
+// An odd last row or column is averaged over the pixels available.
+// The result is origin-based.
+func downscale(src *image.RGBA) *image.RGBA {
+	r := src.Bounds()
+	w, h := (r.Dx()+1)/2, (r.Dy()+1)/2
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [4]int
+			n := 0
+			for dy := 0; dy < 2; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					sx, sy := r.Min.X+2*x+dx, r.Min.Y+2*y+dy
+					if sx >= r.Max.X || sy >= r.Max.Y {
+						continue
+					}
+					p := src.Pix[src.PixOffset(sx, sy):]
+					for k := range sum {
+						sum[k] += int(p[k])
+					}
+					n++
+				}
+			}
+			d := dst.Pix[dst.PixOffset(x, y):]
+			for k := range sum {
+				d[k] = uint8((sum[k] + n/2) / n)
+			}
+		}
+	}
+	return dst
+}
+
+// shrink reduces src by k in each dimension, averaging the r, g and b
+// of each k×k block read straight from src through rowRGB, so no
+// full-size copy is made. Blocks at an uneven right or bottom edge
+// are averaged over the pixels available. The result is opaque and
+// origin-based.
+func shrink(src image.Image, k int) *image.RGBA {
+	r := src.Bounds()
+	w, h := (r.Dx()+k-1)/k, (r.Dy()+k-1)/k
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sum := make([]int, 3*w)
+	var buf []uint8
+	for y := 0; y < h; y++ {
+		for i := range sum {
+			sum[i] = 0
+		}
+		y0 := r.Min.Y + y*k
+		y1 := clampInt(y0+k, y0, r.Max.Y)
+		for sy := y0; sy < y1; sy++ {
+			buf = rowRGB(src, sy, r.Min.X, r.Max.X, buf)
+			for i, v := range buf {
+				sum[i/(3*k)*3+i%3] += int(v)
+			}
+		}
+		for x := 0; x < w; x++ {
+			x0 := r.Min.X + x*k
+			n := (clampInt(x0+k, x0, r.Max.X) - x0) * (y1 - y0)
+			d := dst.Pix[dst.PixOffset(x, y):]
+			for c := 0; c < 3; c++ {
+				d[c] = uint8((sum[3*x+c] + n/2) / n)
+			}
+			d[3] = 255
+		}
+	}
+	return dst
+}
+
+// bilinear samples src at the point (x, y), where pixel centers lie
+// at half-integer coordinates, interpolating its four nearest pixels.
+// Neighbors outside src are clamped to its edge. Points outside src
+// sample as transparent and ok is false.
+func bilinear(src *image.RGBA, x, y float64) (c color.RGBA, ok bool) {
+	r := src.Bounds()
+	if x < float64(r.Min.X) || y < float64(r.Min.Y) || x >= float64(r.Max.X) || y >= float64(r.Max.Y) {
+		return c, false
+	}
+	x, y = x-0.5, y-0.5
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	clamp := func(v, lo, hi int) int {
+		if v < lo {
+			return lo
+		}
+		if v >= hi {
+			return hi - 1
+		}
+		return v
+	}
+	xa, xb := clamp(x0, r.Min.X, r.Max.X), clamp(x0+1, r.Min.X, r.Max.X)
+	ya, yb := clamp(y0, r.Min.Y, r.Max.Y), clamp(y0+1, r.Min.Y, r.Max.Y)
+	p00, p10 := src.Pix[src.PixOffset(xa, ya):], src.Pix[src.PixOffset(xb, ya):]
+	p01, p11 := src.Pix[src.PixOffset(xa, yb):], src.Pix[src.PixOffset(xb, yb):]
+	var v [4]uint8
+	for k := range v {
+		top := float64(p00[k])*(1-fx) + float64(p10[k])*fx
+		bot := float64(p01[k])*(1-fx) + float64(p11[k])*fx
+		v[k] = uint8(top*(1-fy) + bot*fy + 0.5)
+	}
+	return color.RGBA{v[0], v[1], v[2], v[3]}, true
+}
+
+// bilinearRGB is bilinear for the 8-bit r, g and b of any src, read
+// through rowRGB, so that the standard image types are sampled in
+// place rather than converted first.
+func bilinearRGB(src image.Image, x, y float64) (c [3]uint8, ok bool) {
+	r := src.Bounds()
+	if x < float64(r.Min.X) || y < float64(r.Min.Y) || x >= float64(r.Max.X) || y >= float64(r.Max.Y) {
+		return c, false
+	}
+	x, y = x-0.5, y-0.5
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	fx, fy := x-float64(x0), y-float64(y0)
+	xa, xb := clampInt(x0, r.Min.X, r.Max.X-1), clampInt(x0+1, r.Min.X, r.Max.X-1)
+	ya, yb := clampInt(y0, r.Min.Y, r.Max.Y-1), clampInt(y0+1, r.Min.Y, r.Max.Y-1)
+	var p [4][3]uint8
+	for i, q := range [4]image.Point{{xa, ya}, {xb, ya}, {xa, yb}, {xb, yb}} {
+		rowRGB(src, q.Y, q.X, q.X+1, p[i][:0])
+	}
+	for k := range c {
+		top := float64(p[0][k])*(1-fx) + float64(p[1][k])*fx
+		bot := float64(p[2][k])*(1-fx) + float64(p[3][k])*fx
+		c[k] = uint8(top*(1-fy) + bot*fy + 0.5)
+	}
+	return c, true
+}
+
+// sobel returns the Sobel gradient magnitude |gx|+|gy| of the luma
+// (r+g+b)/3 of src, one value per pixel in row-major order from
+// src.Rect.Min. Neighbors outside src are clamped to its edge.
+func sobel(src *image.RGBA) []int {
+	r := src.Bounds()
+	w, h := r.Dx(), r.Dy()
+	lu := make([]int, w*h)
+	for y := 0; y < h; y++ {
+		sp := src.PixOffset(r.Min.X, r.Min.Y+y)
+		for x := 0; x < w; x, sp = x+1, sp+4 {
+			p := src.Pix[sp : sp+3 : sp+3]
+			lu[y*w+x] = (int(p[0]) + int(p[1]) + int(p[2])) / 3
+		}
+	}
+	at := func(x, y int) int {
+		if x < 0 {
+			x = 0
+		} else if x >= w {
+			x = w - 1
+		}
+		if y < 0 {
+			y = 0
+		} else if y >= h {
+			y = h - 1
+		}
+		return lu[y*w+x]
+	}
+	mag := make([]int, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) - at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			if gx < 0 {
+				gx = -gx
+			}
+			if gy < 0 {
+				gy = -gy
+			}
+			mag[y*w+x] = gx + gy
+		}
+	}
+	return mag
+}
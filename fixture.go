@@ -0,0 +1,78 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+)
+
+// fixtureSkin are the colors of the faces drawn by GenerateTestImage.
+var fixtureSkin = []color.RGBA{
+	{224, 172, 140, 255},
+	{198, 134, 102, 255},
+	{160, 110, 80, 255},
+	{120, 80, 60, 255},
+}
+
+// GenerateTestImage draws a reproducible 640×480 test image: up to
+// faces solid ellipses of skin on a noisy background that is never
+// skin, and returns it with the bounds of each ellipse, in the order
+// drawn. The same seed always gives the same image, so it can serve
+// as a golden fixture for tuning or for checking region counts.
+//
+// Each face is one of the colors (224,172,140), (198,134,102),
+// (160,110,80) and (120,80,60), all skin by IsSkin, with semi-axes
+// of 16 to 48 pixels and a height 1 to 1.5 times its width. Faces
+// are at least 4 pixels apart, so each is its own 8-connected
+// region; if a face cannot be placed apart from the others after
+// many tries, fewer faces are drawn. The background has r < 64, so
+// it fails the luminance floor.
+func GenerateTestImage(seed int64, faces int) (img *image.RGBA, regions []image.Rectangle) {
+	const (
+		w, h  = 640, 480
+		gap   = 4
+		tries = 100
+	)
+	rng := rand.New(rand.NewSource(seed))
+	img = image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i] = uint8(20 + rng.Intn(40))
+		img.Pix[i+1] = uint8(60 + rng.Intn(60))
+		img.Pix[i+2] = uint8(110 + rng.Intn(80))
+		img.Pix[i+3] = 255
+	}
+	for f := 0; f < faces; f++ {
+		for t := 0; t < tries; t++ {
+			rx := 16 + rng.Intn(33)
+			ry := rx + rng.Intn(rx/2+1)
+			cx, cy := rx+rng.Intn(w-2*rx), ry+rng.Intn(h-2*ry)
+			b := image.Rect(cx-rx, cy-ry, cx+rx+1, cy+ry+1)
+			if overlaps(b.Inset(-gap), regions) {
+				continue
+			}
+			c := fixtureSkin[rng.Intn(len(fixtureSkin))]
+			var drawn image.Rectangle
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					dx, dy := x-cx, y-cy
+					if dx*dx*ry*ry+dy*dy*rx*rx <= rx*rx*ry*ry {
+						img.SetRGBA(x, y, c)
+						drawn = drawn.Union(image.Rect(x, y, x+1, y+1))
+					}
+				}
+			}
+			regions = append(regions, drawn)
+			break
+		}
+	}
+	return img, regions
+}
+
+func overlaps(r image.Rectangle, rs []image.Rectangle) bool {
+	for _, s := range rs {
+		if r.Overlaps(s) {
+			return true
+		}
+	}
+	return false
+}
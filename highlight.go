@@ -0,0 +1,139 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// highlight reports whether r, g, b is a specular highlight: near
+// white and nearly unsaturated.
+func highlight(r, g, b uint8) bool {
+	const (
+		minLo, maxSpread = 170, 48
+	)
+	hi, lo := maxmin3(r, g, b)
+	return lo >= minLo && hi-lo <= maxSpread
+}
+
+// recoverHighlights sets to c the pixels of mask that are highlights
+// in src and lie in an 8-connected run of highlights enclosed by
+// skin: not touching the edge of the mask, with at least three
+// quarters of the pixels bordering it set in mask. A shiny forehead
+// is filled in; a white wall beside a face is not. It returns the
+// number of pixels set.
+func recoverHighlights(src image.Image, mask draw.Image, c color.Color) (n int) {
+	r := mask.Bounds()
+	w, h := r.Dx(), r.Dy()
+	skin := make([]bool, w*h)
+	hl := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if _, _, _, a := mask.At(r.Min.X+x, r.Min.Y+y).RGBA(); a != 0 {
+				skin[y*w+x] = true
+				continue
+			}
+			cr, cg, cb, _ := src.At(r.Min.X+x, r.Min.Y+y).RGBA()
+			hl[y*w+x] = highlight(to8(cr, cg, cb))
+		}
+	}
+	var (
+		stack []int
+		run   []int
+		own   = make([]bool, w*h)
+	)
+	in := func(j int) bool { return hl[j] }
+	visit := func(j int) {
+		hl[j] = false
+		run = append(run, j)
+	}
+	for j := range hl {
+		if !hl[j] {
+			continue
+		}
+		run = run[:0]
+		stack = flood(w, h, Conn8, j, stack, in, visit)
+		if !enclosed(run, own, skin, w, h) {
+			continue
+		}
+		for _, j := range run {
+			mask.Set(r.Min.X+j%w, r.Min.Y+j/w, c)
+		}
+		n += len(run)
+	}
+	return n
+}
+
+// enclosed reports whether the run of pixels, in a w×h grid, keeps
+// off the edge of the grid and is bordered mostly by skin. Own is
+// scratch space of w×h, false on entry and on return.
+func enclosed(run []int, own, skin []bool, w, h int) bool {
+	for _, j := range run {
+		own[j] = true
+	}
+	defer func() {
+		for _, j := range run {
+			own[j] = false
+		}
+	}()
+	on, off := 0, 0
+	for _, j := range run {
+		x, y := j%w, j/w
+		if x == 0 || y == 0 || x == w-1 || y == h-1 {
+			return false
+		}
+		for _, d := range conn8 {
+			k := (y+d.Y)*w + x + d.X
+			switch {
+			case own[k]:
+			case skin[k]:
+				on++
+			default:
+				off++
+			}
+		}
+	}
+	return on >= 3*off
+}
+
+// neutral reports whether r, g, b is near gray and not dark.
+func neutral(r, g, b uint8) bool {
+	const (
+		minLo, maxSpread = 96, 32
+	)
+	hi, lo := maxmin3(r, g, b)
+	return lo >= minLo && hi-lo <= maxSpread
+}
+
+// bridgeNeutral sets to c the pixels of mask that are neutral in src
+// and set in the closing of mask over a 5×5 window. It returns the
+// number of pixels set.
+func bridgeNeutral(src image.Image, mask draw.Image, c color.Color) (n int) {
+	const (
+		radius = 2
+	)
+	r := mask.Bounds()
+	bin := image.NewAlpha(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if _, _, _, a := mask.At(x, y).RGBA(); a != 0 {
+				bin.Pix[bin.PixOffset(x, y)] = 255
+			}
+		}
+	}
+	closed := Close(bin, radius)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			i := bin.PixOffset(x, y)
+			if bin.Pix[i] != 0 || closed.Pix[i] == 0 {
+				continue
+			}
+			cr, cg, cb, _ := src.At(x, y).RGBA()
+			if neutral(to8(cr, cg, cb)) {
+				mask.Set(x, y, c)
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+var (
+	testSkin  = color.RGBA{198, 134, 102, 255}
+	testBg    = color.RGBA{40, 90, 150, 255}
+	testWhite = color.RGBA{235, 235, 228, 255}
+)
+
+// paint fills r of img with c.
+func paint(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func TestNeutralPassthrough(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 100, 80))
+	paint(src, src.Rect, testBg)
+	paint(src, image.Rect(10, 10, 50, 60), testSkin)
+	gap := image.Rect(26, 40, 34, 43) // teeth inside the face
+	paint(src, gap, testWhite)
+	patch := image.Rect(70, 20, 76, 26) // a white patch on the background
+	paint(src, patch, testWhite)
+
+	plain, _ := SkinMaskWith(src, nil, Options{})
+	bridged, _ := SkinMaskWith(src, nil, Options{NeutralPassthrough: true})
+	for y := gap.Min.Y; y < gap.Max.Y; y++ {
+		for x := gap.Min.X; x < gap.Max.X; x++ {
+			if isSet(plain, x, y) {
+				t.Fatalf("white gap at (%d, %d) is skin without NeutralPassthrough", x, y)
+			}
+			if !isSet(bridged, x, y) {
+				t.Fatalf("white gap at (%d, %d) not bridged", x, y)
+			}
+		}
+	}
+	for y := patch.Min.Y - 2; y < patch.Max.Y+2; y++ {
+		for x := patch.Min.X - 2; x < patch.Max.X+2; x++ {
+			if isSet(bridged, x, y) {
+				t.Fatalf("background at (%d, %d) set by NeutralPassthrough", x, y)
+			}
+		}
+	}
+}
+
+func TestRecoverHighlights(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 100, 80))
+	paint(src, src.Rect, testBg)
+	paint(src, image.Rect(10, 10, 60, 70), testSkin)
+	spot := image.Rect(28, 18, 40, 26) // a shiny forehead
+	paint(src, spot, color.RGBA{250, 246, 240, 255})
+	wall := image.Rect(60, 0, 100, 80) // a white wall beside the face
+	paint(src, wall, color.RGBA{250, 246, 240, 255})
+
+	plain, _ := SkinMaskWith(src, nil, Options{})
+	recovered, _ := SkinMaskWith(src, nil, Options{RecoverHighlights: true})
+	for y := spot.Min.Y; y < spot.Max.Y; y++ {
+		for x := spot.Min.X; x < spot.Max.X; x++ {
+			if isSet(plain, x, y) {
+				t.Fatalf("highlight at (%d, %d) is skin without RecoverHighlights", x, y)
+			}
+			if !isSet(recovered, x, y) {
+				t.Fatalf("highlight at (%d, %d) not recovered", x, y)
+			}
+		}
+	}
+	for y := wall.Min.Y; y < wall.Max.Y; y++ {
+		for x := wall.Min.X; x < wall.Max.X; x++ {
+			if isSet(recovered, x, y) {
+				t.Fatalf("wall at (%d, %d) recovered as a highlight", x, y)
+			}
+		}
+	}
+}
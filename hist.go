@@ -0,0 +1,206 @@
+package face
+
+import (
+	"image"
+	"image/color"
+)
+
+// RGDeltaHistogram counts the signed red-green difference of every
+// pixel in src within r. Bin i holds the number of pixels where
+// r−g == i−255, so the range [-255, 255] maps to bins [0, 510];
+// the last bin is unused.
+//
+// The skin band of SkinMask lies in bins [275, 345]. Plotting the
+// histogram of a dataset shows where its skin cluster sits relative
+// to the background.
+//
+// If src is an *image.RGBA, the pixels are read directly.
+func RGDeltaHistogram(src image.Image, r image.Rectangle) (h [512]uint32) {
+	r = r.Intersect(src.Bounds())
+	if src, ok := src.(*image.RGBA); ok {
+		return rgDeltaHistogramRGBA(src, r)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			r, g, _, _ := src.At(x, y).RGBA()
+			h[int(r>>8)-int(g>>8)+255]++
+		}
+	}
+	return h
+}
+
+func rgDeltaHistogramRGBA(src *image.RGBA, r image.Rectangle) (h [512]uint32) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp := src.PixOffset(r.Min.X, y)
+		ep := sp + r.Dx()*4
+		for pix := src.Pix; sp != ep; sp += 4 {
+			h[int(pix[sp])-int(pix[sp+1])+255]++
+		}
+	}
+	return h
+}
+
+// ChromaCoverage returns, in one pass over src within r, the
+// fraction of pixels classified as skin by SkinMask and the fraction
+// of achromatic pixels, those whose channels differ by at most 12.
+// A grayscale photo has a high achromatic fraction; a color photo
+// without skin has a low one and no skin.
+//
+// If src is an *image.RGBA, the pixels are read directly; other
+// sources are read a row at a time, directly for the standard types
+// of rowRGB.
+func ChromaCoverage(src image.Image, r image.Rectangle) (skin, achromatic float64) {
+	r = r.Intersect(src.Bounds())
+	n := r.Dx() * r.Dy()
+	if n == 0 {
+		return 0, 0
+	}
+	s, a := 0, 0
+	if rgba, ok := src.(*image.RGBA); ok {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			sp := rgba.PixOffset(r.Min.X, y)
+			ep := sp + r.Dx()*4
+			for pix := rgba.Pix; sp != ep; sp += 4 {
+				if skinRGB(pix[sp], pix[sp+1], pix[sp+2]) {
+					s++
+				} else if achromatic8(pix[sp], pix[sp+1], pix[sp+2]) {
+					a++
+				}
+			}
+		}
+	} else {
+		var buf []uint8
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			buf = rowRGB(src, y, r.Min.X, r.Max.X, buf)
+			for i := 0; i < len(buf); i += 3 {
+				if skinRGB(buf[i], buf[i+1], buf[i+2]) {
+					s++
+				} else if achromatic8(buf[i], buf[i+1], buf[i+2]) {
+					a++
+				}
+			}
+		}
+	}
+	return coverage(s, n), coverage(a, n)
+}
+
+// achromatic8 reports whether r, g, b is nearly gray.
+func achromatic8(r, g, b uint8) bool {
+	const (
+		maxSpread = 12
+	)
+	hi, lo := maxmin3(r, g, b)
+	return hi-lo <= maxSpread
+}
+
+// IsGradient reports whether the luma (r+g+b)/3 of src within r is
+// explained by a plane, as in a sky or a stretch of sand, rather
+// than the detail of a face. The plane is fitted by least squares
+// on a grid of at most 64×64 samples, and the region is a gradient
+// if the RMS residual is at most 4. A skin-hued region with high
+// coverage that is also a gradient is unlikely to be a face. Regions
+// with fewer than three samples are not gradients.
+func IsGradient(src image.Image, r image.Rectangle) bool {
+	const (
+		grid        = 64
+		maxResidual = 4
+	)
+	r = r.Intersect(src.Bounds())
+	if r.Empty() {
+		return false
+	}
+	dx := (r.Dx() + grid - 1) / grid
+	dy := (r.Dy() + grid - 1) / grid
+	type sample struct{ x, y, l float64 }
+	var (
+		ps         []sample
+		mx, my, ml float64
+	)
+	for y := r.Min.Y; y < r.Max.Y; y += dy {
+		for x := r.Min.X; x < r.Max.X; x += dx {
+			r, g, b, _ := src.At(x, y).RGBA()
+			p := sample{float64(x), float64(y), float64(r>>8+g>>8+b>>8) / 3}
+			ps = append(ps, p)
+			mx, my, ml = mx+p.x, my+p.y, ml+p.l
+		}
+	}
+	n := float64(len(ps))
+	if n < 3 {
+		return false
+	}
+	mx, my, ml = mx/n, my/n, ml/n
+
+	// Centered, the plane l = ml + a(x−mx) + b(y−my) leaves a 2×2
+	// system for the slopes.
+	var sxx, sxy, syy, sxl, syl float64
+	for _, p := range ps {
+		x, y, l := p.x-mx, p.y-my, p.l-ml
+		sxx, sxy, syy = sxx+x*x, sxy+x*y, syy+y*y
+		sxl, syl = sxl+x*l, syl+y*l
+	}
+	var a, b float64
+	switch det := sxx*syy - sxy*sxy; {
+	case det != 0:
+		a, b = (sxl*syy-syl*sxy)/det, (syl*sxx-sxl*sxy)/det
+	case sxx != 0:
+		a = sxl / sxx
+	case syy != 0:
+		b = syl / syy
+	}
+	var ss float64
+	for _, p := range ps {
+		e := p.l - ml - a*(p.x-mx) - b*(p.y-my)
+		ss += e * e
+	}
+	return ss/n <= maxResidual*maxResidual
+}
+
+// SkinPaletteFraction returns the fraction of the entries of p that
+// SkinMask classifies as skin. An indexed image whose palette has no
+// skin entries cannot contain skin, so this rejects most stickers
+// and icons at the cost of the palette rather than the pixels. It
+// returns 0 for an empty palette.
+func SkinPaletteFraction(p color.Palette) float64 {
+	n := 0
+	for _, c := range p {
+		r, g, b, _ := c.RGBA()
+		if skinRGB(to8(r, g, b)) {
+			n++
+		}
+	}
+	return coverage(n, len(p))
+}
+
+// ClippingRatio returns the fraction of the pixels of src within r
+// with a channel clipped to 0 or 255. Clipped skin, as in a blown-out
+// frame where r, g and b all reach 255, loses the r−g difference the
+// chroma tests rely on, so a high ratio means detection is
+// unreliable. If src is an *image.RGBA, its pixels are read directly.
+func ClippingRatio(src image.Image, r image.Rectangle) float64 {
+	r = r.Intersect(src.Bounds())
+	clipped := func(r, g, b uint8) bool {
+		return r == 0 || g == 0 || b == 0 || r == 255 || g == 255 || b == 255
+	}
+	n := 0
+	if rgba, ok := src.(*image.RGBA); ok {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			sp := rgba.PixOffset(r.Min.X, y)
+			for pix, ep := rgba.Pix, sp+r.Dx()*4; sp != ep; sp += 4 {
+				if clipped(pix[sp], pix[sp+1], pix[sp+2]) {
+					n++
+				}
+			}
+		}
+	} else {
+		var buf []uint8
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			buf = rowRGB(src, y, r.Min.X, r.Max.X, buf)
+			for i := 0; i < len(buf); i += 3 {
+				if clipped(buf[i], buf[i+1], buf[i+2]) {
+					n++
+				}
+			}
+		}
+	}
+	return coverage(n, r.Dx()*r.Dy())
+}
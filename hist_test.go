@@ -0,0 +1,93 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestChromaCoverageNonRGBA(t *testing.T) {
+	r := image.Rect(-4, 3, 60, 45)
+	for _, src := range []image.Image{
+		fuzzSource(1, r, 1),
+		fuzzSource(2, r, 1),
+		fuzzSource(3, r, 1),
+		randYCbCr(image.Rect(0, 0, 64, 48), image.YCbCrSubsampleRatio420, 2),
+		generic{fuzzSource(1, r, 1)},
+	} {
+		region := src.Bounds().Inset(5)
+		skin, achromatic := ChromaCoverage(src, region)
+		ws, wa := ChromaCoverage(atRGBA(src), region)
+		if skin != ws || achromatic != wa {
+			t.Errorf("%T: ChromaCoverage = %v, %v, want %v, %v", src, skin, achromatic, ws, wa)
+		}
+		if _, gray := src.(*image.Gray); gray && achromatic != 1 {
+			t.Errorf("%T: achromatic %v, want 1", src, achromatic)
+		} else if !gray && skin == 0 {
+			t.Errorf("%T: no skin found", src)
+		}
+	}
+}
+
+func TestIsGradient(t *testing.T) {
+	r := image.Rect(-20, 10, 180, 130)
+	// A sky: luma rising along a plane, with the noise of a sensor.
+	rng := rand.New(rand.NewSource(21))
+	sky := image.NewRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			l := 60 + 0.5*float64(x) + 0.4*float64(y) + 4*rng.Float64()
+			sky.SetRGBA(x, y, color.RGBA{uint8(l - 20), uint8(l), uint8(l + 20), 255})
+		}
+	}
+	flat := image.NewRGBA(r)
+	paint(flat, r, testSkin)
+	for _, tc := range []struct {
+		name   string
+		src    image.Image
+		region image.Rectangle
+		want   bool
+	}{
+		{"sky", sky, r, true},
+		{"sky through At", generic{sky}, r.Inset(30), true},
+		{"flat", flat, r, true},
+		{"detail", randRGBA(r, 21), r, false},
+		{"two samples", sky, image.Rect(0, 20, 2, 21), false},
+		{"outside", sky, image.Rect(300, 300, 400, 400), false},
+	} {
+		if got := IsGradient(tc.src, tc.region); got != tc.want {
+			t.Errorf("%s: IsGradient = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestClippingRatio(t *testing.T) {
+	r := image.Rect(-10, 5, 90, 55)
+	rgba := image.NewRGBA(r)
+	paint(rgba, r, testSkin)
+	paint(rgba, image.Rect(-10, 5, 40, 15), color.RGBA{255, 255, 255, 255}) // blown out
+	paint(rgba, image.Rect(40, 5, 90, 10), color.RGBA{200, 0, 90, 255})     // one channel crushed
+	for _, src := range []image.Image{rgba, generic{rgba}} {
+		if got := ClippingRatio(src, r); got != 750.0/5000 {
+			t.Errorf("%T: ClippingRatio = %v, want 0.15", src, got)
+		}
+		// Clipped to src: half of this region is the white band.
+		if got := ClippingRatio(src, image.Rect(-50, 0, 40, 25)); got != 0.5 {
+			t.Errorf("%T: ClippingRatio of a clipped region = %v, want 0.5", src, got)
+		}
+		if got := ClippingRatio(src, image.Rect(200, 200, 300, 300)); got != 0 {
+			t.Errorf("%T: ClippingRatio outside src = %v, want 0", src, got)
+		}
+	}
+}
+
+func TestSkinPaletteFraction(t *testing.T) {
+	p := color.Palette{testSkin, testBg, color.Black, color.NRGBA{198, 134, 102, 255}}
+	if got := SkinPaletteFraction(p); got != 0.5 {
+		t.Errorf("SkinPaletteFraction = %v, want 0.5", got)
+	}
+	if got := SkinPaletteFraction(nil); got != 0 {
+		t.Errorf("empty palette: %v, want 0", got)
+	}
+}
@@ -0,0 +1,49 @@
+package face
+
+import (
+	"image"
+	"image/color"
+)
+
+// MaskedImage is Src seen through Mask: each pixel is that of Src
+// scaled by the alpha of Mask, so skin keeps its color and the rest
+// is transparent. It composites as it is read, without allocating an
+// image, which is cheap when only part of it is consumed. Its bounds
+// are those of Src and Mask where both are defined.
+type MaskedImage struct {
+	Src  image.Image
+	Mask *image.Alpha
+}
+
+// ColorModel returns color.RGBA64Model.
+func (m MaskedImage) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+// Bounds returns the intersection of the bounds of Src and Mask.
+func (m MaskedImage) Bounds() image.Rectangle {
+	return m.Src.Bounds().Intersect(m.Mask.Rect)
+}
+
+// At returns the color of the pixel at (x, y).
+func (m MaskedImage) At(x, y int) color.Color {
+	return m.RGBA64At(x, y)
+}
+
+// RGBA64At returns the color of the pixel at (x, y), premultiplied
+// by the alpha of Mask.
+func (m MaskedImage) RGBA64At(x, y int) color.RGBA64 {
+	if !(image.Point{x, y}.In(m.Bounds())) {
+		return color.RGBA64{}
+	}
+	a := uint32(m.Mask.Pix[m.Mask.PixOffset(x, y)])
+	if a == 0 {
+		return color.RGBA64{}
+	}
+	r, g, b, sa := m.Src.At(x, y).RGBA()
+	if a == 0xff {
+		return color.RGBA64{uint16(r), uint16(g), uint16(b), uint16(sa)}
+	}
+	q := func(v uint32) uint16 { return uint16(v * a / 0xff) }
+	return color.RGBA64{q(r), q(g), q(b), q(sa)}
+}
@@ -0,0 +1,459 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sync"
+)
+
+// Mode selects the colorspace and rules used to classify a pixel
+// as skin.
+type Mode int
+
+const (
+	// ModeRGB applies the red-green difference and ratio rules
+	// directly to RGB. This is the default used by SkinMask.
+	ModeRGB Mode = iota
+
+	// ModeYCbCr bounds the Cb and Cr chroma components, ignoring
+	// luma. It tolerates uneven lighting better than ModeRGB. The
+	// chroma of an *image.YCbCr source is classified as stored,
+	// without a round trip through RGB, so its results can differ
+	// from those through At: for colors outside the RGB gamut, and
+	// by rounding for the odd color on the edge of the skin cluster,
+	// about 1 in 100000 of random in-gamut colors.
+	ModeYCbCr
+
+	// ModeHSV bounds hue, saturation and value. It rejects strongly
+	// saturated reds and oranges that pass the RGB rules.
+	ModeHSV
+
+	// ModeLab bounds the a* and b* components of CIE L*a*b*. It is
+	// the slowest mode.
+	ModeLab
+
+	// ModeNone means no mode is expected to work on the image. It is
+	// returned by SelectMode for achromatic input.
+	ModeNone
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeRGB:
+		return "RGB"
+	case ModeYCbCr:
+		return "YCbCr"
+	case ModeHSV:
+		return "HSV"
+	case ModeLab:
+		return "Lab"
+	case ModeNone:
+		return "None"
+	}
+	return "Mode(?)"
+}
+
+// SkinMaskYCbCr is SkinMaskWith in ModeYCbCr. For a 4:2:0 source,
+// SkinMaskWith with Options.ChromaUpsample also tightens the edges.
+func SkinMaskYCbCr(src image.Image, mask draw.Image) (mask0 draw.Image, cover float64) {
+	return skinMaskColor(src, mask, &Options{Mode: ModeYCbCr})
+}
+
+// SkinMaskHSV is SkinMaskWith in ModeHSV.
+func SkinMaskHSV(src image.Image, mask draw.Image) (mask0 draw.Image, cover float64) {
+	return skinMaskColor(src, mask, &Options{Mode: ModeHSV})
+}
+
+// SkinMaskLab is SkinMaskWith in ModeLab.
+func SkinMaskLab(src image.Image, mask draw.Image) (mask0 draw.Image, cover float64) {
+	return skinMaskColor(src, mask, &Options{Mode: ModeLab})
+}
+
+var auto struct {
+	sync.RWMutex
+	sel func(image.Image) Mode
+}
+
+// SetAutoSelect replaces the heuristic SkinMaskAuto uses to choose
+// a mode. A nil sel restores SelectMode, the default. It is safe to
+// call concurrently with SkinMaskAuto.
+func SetAutoSelect(sel func(src image.Image) Mode) {
+	auto.Lock()
+	auto.sel = sel
+	auto.Unlock()
+}
+
+func autoSelect(src image.Image) Mode {
+	auto.RLock()
+	sel := auto.sel
+	auto.RUnlock()
+	if sel == nil {
+		sel = SelectMode
+	}
+	return sel(src)
+}
+
+// SkinMaskAuto picks a mode for src with the heuristic installed by
+// SetAutoSelect (SelectMode by default) and runs the detection in
+// that mode. See SkinMask for the meaning of mask and the return
+// values. If the selected mode is ModeNone, the mask is
+// left untouched and the coverage is zero.
+func SkinMaskAuto(src image.Image, mask draw.Image) (mask0 draw.Image, cover float64, mode Mode) {
+	mode = autoSelect(src)
+	if mode == ModeNone {
+		if mask == nil {
+			mask = image.NewAlpha(src.Bounds())
+		}
+		return mask, 0, ModeNone
+	}
+	mask, cover = skinMaskColor(src, mask, &Options{Mode: mode})
+	return mask, cover, mode
+}
+
+// BestMode runs detection on src in each of ModeRGB, ModeYCbCr,
+// ModeHSV and ModeLab and returns the mode whose largest 8-connected
+// region covers the most of src, with that coverage. Ties go to the
+// earlier mode. It is slow, running four full detections and region
+// labelings, and meant for offline calibration; SelectMode chooses
+// a mode cheaply by heuristic. An image where no mode finds skin
+// yields ModeNone.
+func BestMode(src image.Image) (Mode, float64) {
+	r := src.Bounds()
+	best, cover := ModeNone, 0.0
+	for _, mode := range []Mode{ModeRGB, ModeYCbCr, ModeHSV, ModeLab} {
+		mask, _ := skinMaskColor(src, nil, &Options{Mode: mode})
+		c, ok := largest(Components(mask.(*image.Alpha), Conn8))
+		if !ok {
+			continue
+		}
+		if v := coverage(c.Area, r.Dx()*r.Dy()); v > cover {
+			best, cover = mode, v
+		}
+	}
+	return best, cover
+}
+
+// SelectMode samples src on a sparse grid and chooses the mode most
+// likely to classify skin well. The heuristic is:
+//
+//	colorfulness < 8     ModeNone (grayscale or nearly so)
+//	luma stddev > 64     ModeYCbCr (uneven or harsh lighting)
+//	colorfulness > 80    ModeHSV (saturated scene)
+//	otherwise            ModeRGB
+//
+// Colorfulness is the Hasler-Süsstrunk metric over the opponent
+// channels r−g and (r+g)/2−b. Luma is (r+g+b)/3.
+func SelectMode(src image.Image) Mode {
+	const (
+		grid = 64
+	)
+	r := src.Bounds()
+	if r.Empty() {
+		return ModeNone
+	}
+	dx := (r.Dx() + grid - 1) / grid
+	dy := (r.Dy() + grid - 1) / grid
+
+	var rg, yb, lu stat
+	for y := r.Min.Y; y < r.Max.Y; y += dy {
+		for x := r.Min.X; x < r.Max.X; x += dx {
+			r, g, b, _ := src.At(x, y).RGBA()
+			R, G, B := float64(r>>8), float64(g>>8), float64(b>>8)
+			rg.add(R - G)
+			yb.add((R+G)/2 - B)
+			lu.add((R + G + B) / 3)
+		}
+	}
+	c := math.Hypot(rg.stddev(), yb.stddev()) + 0.3*math.Hypot(rg.mean(), yb.mean())
+	switch {
+	case c < 8:
+		return ModeNone
+	case lu.stddev() > 64:
+		return ModeYCbCr
+	case c > 80:
+		return ModeHSV
+	}
+	return ModeRGB
+}
+
+// stat accumulates the mean and variance of a sample.
+type stat struct {
+	n, sum, sq float64
+}
+
+func (s *stat) add(v float64) {
+	s.n++
+	s.sum += v
+	s.sq += v * v
+}
+
+func (s *stat) mean() float64 {
+	if s.n == 0 {
+		return 0
+	}
+	return s.sum / s.n
+}
+
+func (s *stat) stddev() float64 {
+	if s.n == 0 {
+		return 0
+	}
+	m := s.mean()
+	v := s.sq/s.n - m*m
+	if v < 0 {
+		return 0
+	}
+	return math.Sqrt(v)
+}
+
+// classifier returns the per-pixel skin test for mode.
+func classifier(mode Mode) func(r, g, b uint8) bool {
+	switch mode {
+	case ModeYCbCr:
+		return skinYCbCr
+	case ModeHSV:
+		return skinHSV
+	case ModeLab:
+		return skinLab
+	case ModeNone:
+		return skinNone
+	}
+	return skinRGB
+}
+
+func skinNone(r, g, b uint8) bool {
+	return false
+}
+
+func skinRGB(r, g, b uint8) bool {
+	return IsSkin(r, g, b)
+}
+
+// IsSkin reports whether the color r, g, b is skin by the ModeRGB
+// rules of SkinMask, the conjunction of PassLuminance, PassDelta and
+// PassRatio.
+func IsSkin(r, g, b uint8) bool {
+	return PassLuminance(r, g, b) && PassDelta(r, g, b) && PassRatio(r, g, b)
+}
+
+// PassLuminance reports whether r, g, b passes the luminance floor
+// of IsSkin, r ≥ 75.
+func PassLuminance(r, g, b uint8) bool {
+	return defaultThresholds.luminance(r)
+}
+
+// PassDelta reports whether r, g, b passes the red-green band of
+// IsSkin, 20 ≤ r−g ≤ 90.
+func PassDelta(r, g, b uint8) bool {
+	return defaultThresholds.delta(r, g)
+}
+
+// PassRatio reports whether r, g, b passes the red-green ratio test
+// of IsSkin, r/g < 2.5. A color with g = 0 fails.
+func PassRatio(r, g, b uint8) bool {
+	return ratio(r, g, 5, 2)
+}
+
+// Rejections counts the pixels eliminated by each rule of IsSkin,
+// applied in order, so each pixel is counted once: by the first rule
+// it fails, or as Accepted.
+type Rejections struct {
+	LumaReject  int // failed PassLuminance
+	DeltaReject int // passed it, failed PassDelta
+	RatioReject int // passed both, failed PassRatio
+	Accepted    int // skin
+}
+
+// RejectionStats classifies the pixels of src within r as SkinMask
+// does, without writing a mask, and returns which rule rejected them,
+// to diagnose why a dataset finds too little skin. If src is an
+// *image.RGBA, its pixels are read directly.
+func RejectionStats(src image.Image, r image.Rectangle) (s Rejections) {
+	r = r.Intersect(src.Bounds())
+	count := func(r, g, b uint8) {
+		switch {
+		case !PassLuminance(r, g, b):
+			s.LumaReject++
+		case !PassDelta(r, g, b):
+			s.DeltaReject++
+		case !PassRatio(r, g, b):
+			s.RatioReject++
+		default:
+			s.Accepted++
+		}
+	}
+	if rgba, ok := src.(*image.RGBA); ok {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			sp := rgba.PixOffset(r.Min.X, y)
+			for pix, ep := rgba.Pix, sp+r.Dx()*4; sp != ep; sp += 4 {
+				count(pix[sp], pix[sp+1], pix[sp+2])
+			}
+		}
+		return s
+	}
+	var buf []uint8
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		buf = rowRGB(src, y, r.Min.X, r.Max.X, buf)
+		for i := 0; i < len(buf); i += 3 {
+			count(buf[i], buf[i+1], buf[i+2])
+		}
+	}
+	return s
+}
+
+func skinYCbCr(r, g, b uint8) bool {
+	_, cb, cr := color.RGBToYCbCr(r, g, b)
+	return skinChroma(cb, cr)
+}
+
+// skinChroma reports whether the chroma cb, cr lies in the skin
+// cluster of ModeYCbCr.
+func skinChroma(cb, cr uint8) bool {
+	const (
+		minCb, maxCb = 77, 127
+		minCr, maxCr = 133, 173
+	)
+	return cb >= minCb && cb <= maxCb && cr >= minCr && cr <= maxCr
+}
+
+// skinHSV is the classifier of ModeHSV, in integer math. A hue
+// within maxH above red or 360−minH below it needs red to be the
+// maximum channel, where the hue is 60°·(g−b)/(max−min).
+func skinHSV(r, g, b uint8) bool {
+	const (
+		maxH       = 50
+		minH       = 340
+		minS, maxS = 23, 75 // percent
+		minV       = 90     // 0.35×255, rounded up
+	)
+	hi, lo := maxmin3(r, g, b)
+	if hi != r || hi == lo || hi < minV {
+		return false
+	}
+	d, v := int(hi-lo), int(hi)
+	if 100*d < minS*v || 100*d > maxS*v {
+		return false
+	}
+	h := 60 * (int(g) - int(b))
+	return h <= maxH*d && h >= (minH-360)*d
+}
+
+func skinLab(r, g, b uint8) bool {
+	const (
+		minL, maxL = 20, 95
+		minA, maxA = 5, 40
+		minB, maxB = 10, 50
+	)
+	l, a, bb := lab(r, g, b)
+	return l >= minL && l <= maxL && a >= minA && a <= maxA && bb >= minB && bb <= maxB
+}
+
+// SkinMaskRef is like SkinMask, but marks the pixels whose color is
+// within tolerance of ref, a sample of the subject's skin, instead of
+// using global thresholds. Distance is the CIE76 ΔE between colors
+// in L*a*b*, where a ΔE of about 2.3 is just noticeable; values from
+// 10 to 20 suit most subjects. Pixels must also pass the ModeRGB
+// rules, so colors near ref that are not skin-like, such as dark
+// browns, are still rejected.
+func SkinMaskRef(src image.Image, ref color.Color, tolerance float64, mask draw.Image) (mask0 draw.Image, cover float64) {
+	r, g, b, _ := ref.RGBA()
+	l0, a0, b0 := lab(to8(r, g, b))
+	t2 := tolerance * tolerance
+	opt := &Options{skin: func(r, g, b uint8) bool {
+		if !skinRGB(r, g, b) {
+			return false
+		}
+		l, a, bb := lab(r, g, b)
+		return (l-l0)*(l-l0)+(a-a0)*(a-a0)+(bb-b0)*(bb-b0) <= t2
+	}}
+	return skinMaskColor(src, mask, opt)
+}
+
+// linear maps an 8-bit sRGB component to linear light.
+var linear = func() (t [256]float64) {
+	for i := range t {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			t[i] = v / 12.92
+		} else {
+			t[i] = math.Pow((v+0.055)/1.055, 2.4)
+		}
+	}
+	return t
+}()
+
+// lab converts sRGB to CIE L*a*b* under a D65 white point.
+func lab(r, g, b uint8) (l, a, bb float64) {
+	R, G, B := linear[r], linear[g], linear[b]
+	x := (0.4124*R + 0.3576*G + 0.1805*B) / 0.95047
+	y := 0.2126*R + 0.7152*G + 0.0722*B
+	z := (0.0193*R + 0.1192*G + 0.9505*B) / 1.08883
+	fx, fy, fz := labf(x), labf(y), labf(z)
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+func labf(t float64) float64 {
+	const e = 216.0 / 24389
+	if t > e {
+		return math.Cbrt(t)
+	}
+	return (24389.0/27*t + 16) / 116
+}
+
+// maxmin3 returns the largest and smallest of r, g and b.
+func maxmin3(r, g, b uint8) (hi, lo uint8) {
+	hi, lo = r, r
+	if g > hi {
+		hi = g
+	}
+	if b > hi {
+		hi = b
+	}
+	if g < lo {
+		lo = g
+	}
+	if b < lo {
+		lo = b
+	}
+	return hi, lo
+}
+
+// upsampleChroma returns a 4:4:4 copy of the 4:2:0 image src whose
+// chroma is interpolated bilinearly. Chroma samples are sited at the
+// centers of their 2×2 blocks, as in JPEG, so pixel x lies a quarter
+// of a sample from the nearest and three quarters from the next.
+func upsampleChroma(src *image.YCbCr) *image.YCbCr {
+	r := src.Rect
+	dst := image.NewYCbCr(r, image.YCbCrSubsampleRatio444)
+	if r.Empty() {
+		return dst
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		copy(dst.Y[dst.YOffset(r.Min.X, y):], src.Y[src.YOffset(r.Min.X, y):src.YOffset(r.Min.X, y)+r.Dx()])
+	}
+	// In quarter samples, pixel x is at 2x−1; the chroma columns and
+	// rows of src span those of its first and last pixels.
+	cx0, cx1 := r.Min.X/2, (r.Max.X-1)/2
+	cy0, cy1 := r.Min.Y/2, (r.Max.Y-1)/2
+	at := func(p []uint8, cx, cy int) int {
+		cx, cy = clampInt(cx, cx0, cx1), clampInt(cy, cy0, cy1)
+		return int(p[(cy-cy0)*src.CStride+cx-cx0])
+	}
+	lerp := func(p []uint8, x, y int) uint8 {
+		qx, qy := 2*x-1, 2*y-1
+		u, v := qx>>2, qy>>2
+		fx, fy := qx-u*4, qy-v*4
+		s := (4-fy)*((4-fx)*at(p, u, v)+fx*at(p, u+1, v)) +
+			fy*((4-fx)*at(p, u, v+1)+fx*at(p, u+1, v+1))
+		return uint8((s + 8) / 16)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		i := dst.COffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x, i = x+1, i+1 {
+			dst.Cb[i], dst.Cr[i] = lerp(src.Cb, x, y), lerp(src.Cr, x, y)
+		}
+	}
+	return dst
+}
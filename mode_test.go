@@ -0,0 +1,225 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+// subsampled returns a w×h 4:2:0 image, skin left of column edge and
+// bg from it on, whose chroma is box-averaged over each 2×2 block as
+// a JPEG encoder does.
+func subsampled(w, h, edge int, skin, bg color.RGBA) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	cb := make([]int, w*h)
+	cr := make([]int, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := bg
+			if x < edge {
+				c = skin
+			}
+			yy, b, r := color.RGBToYCbCr(c.R, c.G, c.B)
+			img.Y[img.YOffset(x, y)] = yy
+			cb[y*w+x], cr[y*w+x] = int(b), int(r)
+		}
+	}
+	for y := 0; y < h; y += 2 {
+		for x := 0; x < w; x += 2 {
+			i := img.COffset(x, y)
+			j := y*w + x
+			img.Cb[i] = uint8((cb[j] + cb[j+1] + cb[j+w] + cb[j+w+1] + 2) / 4)
+			img.Cr[i] = uint8((cr[j] + cr[j+1] + cr[j+w] + cr[j+w+1] + 2) / 4)
+		}
+	}
+	return img
+}
+
+// boundaryError returns how many pixels, over all rows, the mask of
+// a 4:2:0 edge between skin and bg is off from classifying the colors
+// before subsampling, for each edge column in edges.
+func boundaryError(mode Mode, upsample bool, skin, bg color.RGBA, edges []int) int {
+	const (
+		w, h = 40, 10
+	)
+	is := classifier(mode)
+	sum := 0
+	for _, e := range edges {
+		want := 0
+		for x := 0; x < w; x++ {
+			c := bg
+			if x < e {
+				c = skin
+			}
+			if is(c.R, c.G, c.B) {
+				want++
+			}
+		}
+		mask, _ := SkinMaskWith(subsampled(w, h, e, skin, bg), nil, Options{Mode: mode, ChromaUpsample: upsample})
+		a := mask.(*image.Alpha)
+		for y := 0; y < h; y++ {
+			n := 0
+			for x := 0; x < w; x++ {
+				if a.Pix[y*a.Stride+x] != 0 {
+					n++
+				}
+			}
+			if n > want {
+				sum += n - want
+			} else {
+				sum += want - n
+			}
+		}
+	}
+	return sum
+}
+
+func TestChromaUpsampleBoundary(t *testing.T) {
+	skins := []color.RGBA{{200, 140, 110, 255}, {160, 110, 90, 255}, {230, 180, 150, 255}}
+	bgs := []color.RGBA{
+		{150, 150, 150, 255}, {90, 160, 200, 255}, {60, 120, 60, 255},
+		{230, 230, 240, 255}, {40, 40, 40, 255}, {120, 60, 160, 255},
+	}
+	var edges []int
+	for e := 10; e < 30; e++ {
+		edges = append(edges, e)
+	}
+	for _, mode := range []Mode{ModeRGB, ModeHSV, ModeYCbCr} {
+		nearest, bilinear := 0, 0
+		for _, s := range skins {
+			for _, bg := range bgs {
+				nearest += boundaryError(mode, false, s, bg, edges)
+				bilinear += boundaryError(mode, true, s, bg, edges)
+			}
+		}
+		t.Logf("%v: boundary error %d pixels nearest, %d bilinear", mode, nearest, bilinear)
+		// ChromaUpsample documents that it tightens the edges of
+		// every mode but ModeYCbCr, which it widens.
+		if worse := bilinear > nearest; worse != (mode == ModeYCbCr) {
+			t.Errorf("%v: bilinear %d, nearest %d; ChromaUpsample's doc is out of date", mode, bilinear, nearest)
+		}
+	}
+}
+
+func TestPredicates(t *testing.T) {
+	// Every r, g pair, with b varying so it is seen not to matter.
+	src := image.NewRGBA(image.Rect(0, 0, 256, 256))
+	var want Rejections
+	var set []bool
+	custom := DefaultThresholds().skin()
+	for g := 0; g < 256; g++ {
+		for r := 0; r < 256; r++ {
+			c := color.RGBA{uint8(r), uint8(g), uint8(r ^ g), 255}
+			src.SetRGBA(r, g, c)
+			l, d, q := PassLuminance(c.R, c.G, c.B), PassDelta(c.R, c.G, c.B), PassRatio(c.R, c.G, c.B)
+			skin := IsSkin(c.R, c.G, c.B)
+			if skin != (l && d && q) || custom(c.R, c.G, c.B) != skin {
+				t.Fatalf("%v: IsSkin %v, thresholds %v; luminance %v, delta %v, ratio %v", c, skin, custom(c.R, c.G, c.B), l, d, q)
+			}
+			switch {
+			case !l:
+				want.LumaReject++
+			case !d:
+				want.DeltaReject++
+			case !q:
+				want.RatioReject++
+			default:
+				want.Accepted++
+			}
+			set = append(set, skin)
+		}
+	}
+
+	n := want.Accepted
+	for _, src := range []image.Image{src, generic{src}} {
+		mask, cover := SkinMask(src, nil)
+		checkMask(t, mask, set)
+		if wc := coverage(n, 256*256); cover != wc || SkinCoverage(src) != wc {
+			t.Errorf("%T: coverage %v, SkinCoverage %v; want %v", src, cover, SkinCoverage(src), wc)
+		}
+		if got := RejectionStats(src, src.Bounds()); got != want {
+			t.Errorf("%T: RejectionStats %+v, want %+v", src, got, want)
+		}
+	}
+	mask := image.NewAlpha(src.Rect)
+	if cover := SkinMaskRGBA(src, mask); cover != coverage(n, 256*256) {
+		t.Errorf("SkinMaskRGBA: coverage %v, want %v", cover, coverage(n, 256*256))
+	}
+	checkMask(t, mask, set)
+}
+
+// inEllipse reports whether x, y is within the ellipse inscribed in
+// one of rs.
+func inEllipse(rs []image.Rectangle) func(x, y int) bool {
+	return func(x, y int) bool {
+		for _, r := range rs {
+			ax, ay := float64(r.Dx())/2, float64(r.Dy())/2
+			dx := (float64(x) + 0.5 - float64(r.Min.X) - ax) / ax
+			dy := (float64(y) + 0.5 - float64(r.Min.Y) - ay) / ay
+			if dx*dx+dy*dy <= 1 {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func TestModes(t *testing.T) {
+	src, faces := GenerateTestImage(3, 4)
+	in := inEllipse(faces)
+	var area int
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			if in(x, y) {
+				area++
+			}
+		}
+	}
+	wrappers := map[Mode]func(image.Image, draw.Image) (draw.Image, float64){
+		ModeRGB:   SkinMask,
+		ModeYCbCr: SkinMaskYCbCr,
+		ModeHSV:   SkinMaskHSV,
+		ModeLab:   SkinMaskLab,
+	}
+	for _, mode := range []Mode{ModeRGB, ModeYCbCr, ModeHSV, ModeLab} {
+		mask, cover := SkinMaskWith(src, nil, Options{Mode: mode})
+		inside, outside := countSet(mask, in)
+		if inside < area*95/100 || outside > area/100 || cover != coverage(inside+outside, 640*480) {
+			t.Errorf("%v: %d of %d face pixels and %d others, coverage %v", mode, inside, area, outside, cover)
+		}
+		wmask, wcover := wrappers[mode](src, nil)
+		if wcover != cover || !equalAlpha(wmask.(*image.Alpha), mask.(*image.Alpha)) {
+			t.Errorf("%v: the wrapper differs from SkinMaskWith", mode)
+		}
+	}
+	if mask, cover := SkinMaskWith(src, nil, Options{Mode: ModeNone}); cover != 0 {
+		inside, outside := countSet(mask, in)
+		t.Errorf("None: %d face pixels and %d others set, coverage %v", inside, outside, cover)
+	}
+}
+
+func TestYCbCrNative(t *testing.T) {
+	// YCbCr converted from random, so in-gamut, RGB.
+	rgba := image.NewRGBA(image.Rect(0, 0, 400, 400))
+	rand.New(rand.NewSource(10)).Read(rgba.Pix)
+	src := image.NewYCbCr(rgba.Rect, image.YCbCrSubsampleRatio444)
+	for i, p := 0, rgba.Pix; i < len(src.Y); i, p = i+1, p[4:] {
+		src.Y[i], src.Cb[i], src.Cr[i] = color.RGBToYCbCr(p[0], p[1], p[2])
+	}
+	native, _ := SkinMaskYCbCr(src, nil)
+	at, _ := SkinMaskYCbCr(generic{src}, nil)
+	a, b := native.(*image.Alpha), at.(*image.Alpha)
+	n := 0
+	for i := range a.Pix {
+		if a.Pix[i] != b.Pix[i] {
+			n++
+		}
+	}
+	// Rounding in the conversion to RGB flips the odd pixel on the
+	// edge of the cluster; here 1 of 160000.
+	if n > len(a.Pix)/10000 {
+		t.Errorf("native and At paths disagree on %d of %d pixels", n, len(a.Pix))
+	}
+}
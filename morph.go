@@ -0,0 +1,148 @@
+package face
+
+import (
+	"image"
+)
+
+// CleanOptions are the steps SkinMaskClean applies to the raw mask,
+// in the order of the fields. Zero values skip a step. Windows are
+// squares of side 2×radius+1 clipped to the mask.
+type CleanOptions struct {
+	// Median keeps a pixel if more than half of its window is set,
+	// the median of a binary mask, removing speckle.
+	Median int
+
+	// Erode keeps a pixel only if its whole window is set.
+	Erode int
+
+	// Dilate sets a pixel if any of its window is set. Erode then
+	// Dilate with the same radius is an opening.
+	Dilate int
+
+	// MinArea clears 8-connected regions of fewer pixels.
+	MinArea int
+}
+
+// SkinMaskClean detects skin in src and cleans the mask as opt
+// directs. It returns both the raw mask, as from SkinMask, and the
+// cleaned one, with their coverages, so what the cleaning removed
+// can be seen.
+func SkinMaskClean(src image.Image, opt CleanOptions) (raw, clean *image.Alpha, rawCover, cleanCover float64) {
+	m, rawCover := skinMaskColor(src, nil, &Options{})
+	raw = m.(*image.Alpha)
+	clean = image.NewAlpha(raw.Rect)
+	copy(clean.Pix, raw.Pix)
+	if opt.Median > 0 {
+		clean = window(clean, opt.Median, func(n, area int) bool { return 2*n > area })
+	}
+	if opt.Erode > 0 {
+		clean = Erode(clean, opt.Erode)
+	}
+	if opt.Dilate > 0 {
+		clean = Dilate(clean, opt.Dilate)
+	}
+	if opt.MinArea > 0 {
+		dropSmall(clean, opt.MinArea)
+	}
+	n := 0
+	for _, v := range clean.Pix {
+		if v != 0 {
+			n++
+		}
+	}
+	r := clean.Bounds()
+	return raw, clean, rawCover, coverage(n, r.Dx()*r.Dy())
+}
+
+// Erode returns a copy of mask where a pixel is 255 only if the whole
+// of its window, a square of side 2×radius+1 clipped to mask, is set,
+// and 0 otherwise. It shrinks regions and removes specks narrower
+// than the window. A radius of 0 or less only sets nonzero pixels to
+// 255. The cost does not depend on radius.
+func Erode(mask *image.Alpha, radius int) *image.Alpha {
+	return window(mask, radius, func(n, area int) bool { return n == area })
+}
+
+// Dilate is the dual of Erode: a pixel is 255 if any of its window is
+// set. It grows regions and fills holes narrower than the window.
+func Dilate(mask *image.Alpha, radius int) *image.Alpha {
+	return window(mask, radius, func(n, area int) bool { return n > 0 })
+}
+
+// Open erodes then dilates mask with the same radius, removing
+// regions and spurs narrower than the window while keeping the
+// shape of larger regions.
+func Open(mask *image.Alpha, radius int) *image.Alpha {
+	return Dilate(Erode(mask, radius), radius)
+}
+
+// Close dilates then erodes mask with the same radius, filling holes
+// and gaps narrower than the window while keeping the shape of
+// larger regions.
+func Close(mask *image.Alpha, radius int) *image.Alpha {
+	return Erode(Dilate(mask, radius), radius)
+}
+
+// window returns a mask of the bounds of mask, where a pixel is 255
+// if keep reports true for the number n of nonzero pixels in its
+// window of the given radius, clipped to mask, of area pixels. The
+// counts come from an integral image, so the cost does not depend
+// on radius. A negative radius is taken as 0.
+func window(mask *image.Alpha, radius int, keep func(n, area int) bool) *image.Alpha {
+	if radius < 0 {
+		radius = 0
+	}
+	r := mask.Bounds()
+	w, h := r.Dx(), r.Dy()
+	sum := integral(mask)
+	out := image.NewAlpha(r)
+	for y := 0; y < h; y++ {
+		y0, y1 := clampInt(y-radius, 0, h), clampInt(y+radius+1, 0, h)
+		for x := 0; x < w; x++ {
+			x0, x1 := clampInt(x-radius, 0, w), clampInt(x+radius+1, 0, w)
+			n := boxSum(sum, w, x0, y0, x1, y1)
+			if keep(n, (x1-x0)*(y1-y0)) {
+				out.Pix[y*out.Stride+x] = 255
+			}
+		}
+	}
+	return out
+}
+
+// dropSmall clears the 8-connected regions of mask of fewer than
+// minArea pixels.
+func dropSmall(mask *image.Alpha, minArea int) {
+	labels, cs := label(mask, Conn8)
+	at := pixAt(mask)
+	for j, id := range labels {
+		if id != 0 && cs[id-1].Area < minArea {
+			mask.Pix[at(j)] = 0
+		}
+	}
+}
+
+// integral returns the integral image of the nonzero pixels of mask:
+// entry y×(w+1)+x counts those above and left of (x, y), relative to
+// the origin of mask, where w is its width.
+func integral(mask *image.Alpha) []int {
+	r := mask.Bounds()
+	w, h := r.Dx(), r.Dy()
+	at := pixAt(mask)
+	sum := make([]int, (w+1)*(h+1))
+	for y := 0; y < h; y++ {
+		row := 0
+		for x := 0; x < w; x++ {
+			if mask.Pix[at(y*w+x)] != 0 {
+				row++
+			}
+			sum[(y+1)*(w+1)+x+1] = sum[y*(w+1)+x+1] + row
+		}
+	}
+	return sum
+}
+
+// boxSum returns the sum over [x0, x1)×[y0, y1) of the integral
+// image sum of a grid of width w.
+func boxSum(sum []int, w, x0, y0, x1, y1 int) int {
+	return sum[y1*(w+1)+x1] - sum[y0*(w+1)+x1] - sum[y1*(w+1)+x0] + sum[y0*(w+1)+x0]
+}
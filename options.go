@@ -0,0 +1,377 @@
+package face
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"time"
+)
+
+// Options configures SkinMaskWith. The zero value is the behavior
+// of SkinMask.
+type Options struct {
+	// Mode selects the skin classifier.
+	Mode Mode
+
+	// Thresholds bound the ModeRGB skin band. The zero value selects
+	// those returned by DefaultThresholds.
+	Thresholds Thresholds
+
+	// AdaptLuminance scales the ModeRGB luminance gates to the
+	// brightness of the image before detection. The mean luma
+	// (r+g+b)/3 of src is estimated on a sparse grid and
+	//
+	//	k = clamp(mean/128, 0.4, 1.2)
+	//	MinR' = MinR·k
+	//	MinRGDelta' = MinRGDelta·k
+	//
+	// so a dim image relaxes the gates and a bright one tightens
+	// them. The upper clamp is low because bright images clip, which
+	// compresses r−g rather than stretching it.
+	AdaptLuminance bool
+
+	// Fill is the alpha value written to the mask for skin pixels.
+	// Zero selects 255. Other values are clamped to [0, 255].
+	Fill int
+
+	// Color is written for skin pixels to masks other than an
+	// *image.Alpha, such as an *image.RGBA canvas drawn over
+	// directly. Nil selects color.Alpha{Fill}.
+	Color color.Color
+
+	// AsConstraint treats the nonzero pixels of the mask passed to
+	// SkinMaskWith as a region of interest. Only those pixels are
+	// classified: skin pixels are set and the rest are cleared, as
+	// if the detection were ANDed with the incoming mask. Pixels
+	// outside the region are left untouched and the coverage is
+	// relative to the region's area. It has no effect on a nil mask.
+	AsConstraint bool
+
+	// Supersample classifies each pixel at 2×2 points within it,
+	// sampling the source bilinearly, and sets the mask to the
+	// fraction of points found to be skin: 0, 64, 128, 192 or 255
+	// 255ths of the fill. Curved edges are then antialiased without a
+	// separate feathering pass, at about four times the cost. Each
+	// pixel counts toward the coverage by the fraction of its points
+	// found to be skin.
+	Supersample bool
+
+	// ChromaUpsample, for an *image.YCbCr source with 4:2:0 chroma,
+	// interpolates Cb and Cr bilinearly between the chroma samples
+	// rather than repeating each over its 2×2 block, smoothing the
+	// two-pixel steps that block chroma leaves along the edges of the
+	// mask. Other sources are unaffected.
+	//
+	// It helps the modes that classify in RGB: on hard edges between
+	// skin and background it trims the boundary error of ModeRGB and
+	// ModeHSV by a tenth or so. It hurts ModeYCbCr, widening its
+	// boundary error by about a quarter, so leave it off in that mode.
+	ChromaUpsample bool
+
+	// RecoverHighlights adds to the mask the specular highlights of
+	// shiny skin, near-white pixels that fail the chroma tests and
+	// would leave holes in a forehead or nose. A run of highlights
+	// is added if skin surrounds it, bordering at least three
+	// quarters of it, and it does not reach the edge of the mask.
+	RecoverHighlights bool
+
+	// NeutralPassthrough lets near-gray pixels, such as teeth and
+	// the whites of eyes, take the state of the skin around them: a
+	// morphological closing over a 5×5 window is applied to the
+	// mask, but only pixels with lo ≥ 96 and hi−lo ≤ 32 of their
+	// channels are set by it. Small neutral gaps within a face are
+	// bridged without pulling in colored background.
+	NeutralPassthrough bool
+
+	// LargestOnly reports as the coverage the area of the largest
+	// 8-connected skin region over the area classified, that of the
+	// mask or, with AsConstraint, of the region, rather than that of
+	// all skin, so scattered skin-colored noise does not add up to a
+	// face.
+	LargestOnly bool
+
+	// MaxPixels, if positive, is the largest source area, in pixels,
+	// SkinMaskWith and SkinMaskChecked accept, guarding servers
+	// against images that decode to huge sizes. A larger source is
+	// refused before any mask is allocated, unless DownscaleLarge is
+	// set. The other functions taking Options do not enforce it.
+	MaxPixels int
+
+	// DownscaleLarge has SkinMaskWith and SkinMaskChecked reduce a
+	// source larger than MaxPixels by the smallest power of two that
+	// fits and detect skin in the result instead of refusing it.
+	// Blocks are averaged as they are read from the source, which is
+	// never copied at full size. The mask is then allocated at the reduced, origin-based
+	// bounds; a mask passed in is not downscaled into, so the source
+	// is refused as without DownscaleLarge.
+	DownscaleLarge bool
+
+	// OnStats, if not nil, is called once per detection with the
+	// number of pixels classified, the number found to be skin and
+	// the time taken. It runs on the caller's goroutine after the
+	// mask is written.
+	OnStats func(pixels, skin int, dur time.Duration)
+
+	// Weights weigh the cues blended by FaceScoreWith.
+	Weights ScoreWeights
+
+	// skin, if not nil, replaces the classifier selected by Mode.
+	skin func(r, g, b uint8) bool
+}
+
+// Thresholds are the bounds of the ModeRGB skin band. A pixel is
+// skin if r ≥ MinR, MinRGDelta ≤ r−g ≤ MaxRGDelta and r/g is less
+// than MaxRGRatio. A pixel with g = 0 has an infinite ratio and is
+// never skin.
+type Thresholds struct {
+	MinR       uint8
+	MinRGDelta uint8
+	MaxRGDelta uint8
+	MaxRGRatio float64
+}
+
+var defaultThresholds = Thresholds{
+	MinR:       75,
+	MinRGDelta: 20,
+	MaxRGDelta: 90,
+	MaxRGRatio: 2.5,
+}
+
+// DefaultThresholds returns the thresholds used by SkinMask.
+func DefaultThresholds() Thresholds {
+	return defaultThresholds
+}
+
+// Preset names thresholds tuned for a lighting condition or range of
+// skin tones, as starting points for calibration; see Calibrate to
+// fit thresholds to a labeled dataset.
+type Preset int
+
+const (
+	PresetDefault   Preset = iota // DefaultThresholds
+	PresetLowLight                // dim scenes: lower floor and band
+	PresetBright                  // overexposed scenes: clipping compresses r−g
+	PresetWarmLight               // tungsten light: skin shifts toward orange
+	PresetDeepSkin                // dark skin tones: lower floor, wider ratio
+)
+
+func (p Preset) String() string {
+	switch p {
+	case PresetDefault:
+		return "Default"
+	case PresetLowLight:
+		return "LowLight"
+	case PresetBright:
+		return "Bright"
+	case PresetWarmLight:
+		return "WarmLight"
+	case PresetDeepSkin:
+		return "DeepSkin"
+	}
+	return "Preset(?)"
+}
+
+// Thresholds returns the thresholds of p, relative to the default
+// MinR 75, MinRGDelta 20, MaxRGDelta 90 and MaxRGRatio 2.5:
+//
+//	PresetLowLight   MinR 45, MinRGDelta 12
+//	PresetBright     MinR 100, MinRGDelta 12
+//	PresetWarmLight  MinRGDelta 30, MaxRGDelta 120, MaxRGRatio 3
+//	PresetDeepSkin   MinR 45, MinRGDelta 12, MaxRGRatio 3
+//
+// An unknown preset returns the default thresholds.
+func (p Preset) Thresholds() Thresholds {
+	t := defaultThresholds
+	switch p {
+	case PresetLowLight:
+		t.MinR, t.MinRGDelta = 45, 12
+	case PresetBright:
+		t.MinR, t.MinRGDelta = 100, 12
+	case PresetWarmLight:
+		t.MinRGDelta, t.MaxRGDelta, t.MaxRGRatio = 30, 120, 3
+	case PresetDeepSkin:
+		t.MinR, t.MinRGDelta, t.MaxRGRatio = 45, 12, 3
+	}
+	return t
+}
+
+// skin returns the skin test bounded by t. The ratio test is done
+// as r·den < g·num, with MaxRGRatio as the fraction num/den, so no
+// division is done per pixel.
+func (t Thresholds) skin() func(r, g, b uint8) bool {
+	num, den := t.ratio()
+	return func(r, g, b uint8) bool {
+		return t.band(r, g, num, den)
+	}
+}
+
+// ratio returns MaxRGRatio as a fraction num/den, to within 1/256.
+func (t Thresholds) ratio() (num, den int) {
+	const (
+		den0   = 256
+		maxNum = 1 << 20
+	)
+	switch q := t.MaxRGRatio * den0; {
+	case q <= 0:
+		return 0, den0
+	case q >= maxNum:
+		return maxNum, den0
+	default:
+		return int(q + 0.5), den0
+	}
+}
+
+func (t Thresholds) band(r, g uint8, num, den int) bool {
+	return t.luminance(r) && t.delta(r, g) && ratio(r, g, num, den)
+}
+
+func (t Thresholds) luminance(r uint8) bool {
+	return r >= t.MinR
+}
+
+func (t Thresholds) delta(r, g uint8) bool {
+	return r >= g && r-g >= t.MinRGDelta && r-g <= t.MaxRGDelta
+}
+
+// ratio reports whether r/g < num/den. For g = 0 the comparison is
+// r·den < 0, which fails, so an infinite ratio is rejected without a
+// branch.
+func ratio(r, g uint8, num, den int) bool {
+	return int(r)*den < int(g)*num
+}
+
+// classifier returns the per-pixel skin test selected by o for src.
+func (o *Options) classifier(src image.Image) func(r, g, b uint8) bool {
+	skin, _ := o.classify(src)
+	return skin
+}
+
+// classify is classifier, also reporting whether the test is that of
+// IsSkin, which the fast paths then call directly so it is inlined.
+func (o *Options) classify(src image.Image) (skin func(r, g, b uint8) bool, isSkin bool) {
+	if o.skin != nil {
+		return o.skin, false
+	}
+	if o.Mode != ModeRGB {
+		return classifier(o.Mode), false
+	}
+	t := o.Thresholds
+	if t == (Thresholds{}) {
+		t = defaultThresholds
+	}
+	if o.AdaptLuminance {
+		t = t.adapt(meanLuma(src))
+	}
+	if t == defaultThresholds {
+		return skinRGB, true
+	}
+	return t.skin(), false
+}
+
+// fixed returns a copy of o whose classifier no longer depends on
+// the source, chosen for the whole of src, so that detection split
+// into parts of src classifies as the whole would.
+func (o *Options) fixed(src image.Image) Options {
+	f := *o
+	if o.skin == nil && o.Mode == ModeRGB && o.AdaptLuminance {
+		f.skin = o.classifier(src)
+	}
+	return f
+}
+
+// adapt scales the luminance gates of t for an image of the given
+// mean luma. See Options.AdaptLuminance.
+func (t Thresholds) adapt(mean float64) Thresholds {
+	k := mean / 128
+	if k < 0.4 {
+		k = 0.4
+	} else if k > 1.2 {
+		k = 1.2
+	}
+	scale := func(v uint8) uint8 {
+		if v := float64(v) * k; v < 255 {
+			return uint8(v + 0.5)
+		}
+		return 255
+	}
+	t.MinR = scale(t.MinR)
+	t.MinRGDelta = scale(t.MinRGDelta)
+	return t
+}
+
+// meanLuma estimates the mean (r+g+b)/3 of src on a sparse grid.
+func meanLuma(src image.Image) float64 {
+	const (
+		grid = 64
+	)
+	r := src.Bounds()
+	if r.Empty() {
+		return 0
+	}
+	dx := (r.Dx() + grid - 1) / grid
+	dy := (r.Dy() + grid - 1) / grid
+	var lu stat
+	for y := r.Min.Y; y < r.Max.Y; y += dy {
+		for x := r.Min.X; x < r.Max.X; x += dx {
+			r, g, b, _ := src.At(x, y).RGBA()
+			lu.add(float64(r>>8+g>>8+b>>8) / 3)
+		}
+	}
+	return lu.mean()
+}
+
+// fill returns the clamped mask value for skin pixels.
+func (o *Options) fill() uint8 {
+	switch {
+	case o.Fill == 0:
+		return 255
+	case o.Fill < 0:
+		return 0
+	case o.Fill > 255:
+		return 255
+	}
+	return uint8(o.Fill)
+}
+
+// color returns the color written for skin pixels to non-alpha masks.
+func (o *Options) color() color.Color {
+	if o.Color == nil {
+		return color.Alpha{o.fill()}
+	}
+	return o.Color
+}
+
+// SkinMaskWith is like SkinMask, but configured by opt. It enforces
+// opt.MaxPixels and opt.DownscaleLarge as SkinMaskChecked does, but
+// returns a nil mask and 0 for a source it refuses.
+func SkinMaskWith(src image.Image, mask draw.Image, opt Options) (mask0 draw.Image, cover float64) {
+	mask, cover, _ = SkinMaskChecked(src, mask, opt)
+	return mask, cover
+}
+
+// ErrTooLarge is returned by SkinMaskChecked for a source larger
+// than Options.MaxPixels.
+var ErrTooLarge = errors.New("face: image too large")
+
+// SkinMaskChecked is SkinMaskWith enforcing opt.MaxPixels and
+// opt.DownscaleLarge. It returns ErrTooLarge, with a nil mask, for a
+// source it refuses.
+func SkinMaskChecked(src image.Image, mask draw.Image, opt Options) (mask0 draw.Image, cover float64, err error) {
+	r := src.Bounds()
+	// The area may overflow an int where it is 32 bits.
+	limit := int64(opt.MaxPixels)
+	if limit <= 0 || int64(r.Dx())*int64(r.Dy()) <= limit {
+		mask, cover = skinMaskColor(src, mask, &opt)
+		return mask, cover, nil
+	}
+	if !opt.DownscaleLarge || mask != nil {
+		return nil, 0, ErrTooLarge
+	}
+	k := 1
+	for w, h := int64(r.Dx()), int64(r.Dy()); w*h > limit; k *= 2 {
+		w, h = (w+1)/2, (h+1)/2
+	}
+	mask, cover = skinMaskColor(shrink(src, k), nil, &opt)
+	return mask, cover, nil
+}
@@ -0,0 +1,277 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/rand"
+	"runtime"
+	"testing"
+)
+
+func TestSkinMaskCheckedMaxPixels(t *testing.T) {
+	src, _ := GenerateTestImage(1, 3)
+	r := src.Bounds()
+	area := r.Dx() * r.Dy()
+
+	_, want := SkinMask(src, nil)
+	if _, cover, err := SkinMaskChecked(src, nil, Options{MaxPixels: area}); err != nil || cover != want {
+		t.Errorf("at the limit: cover %v, err %v; want %v, nil", cover, err, want)
+	}
+	if mask, _, err := SkinMaskChecked(src, nil, Options{MaxPixels: area - 1}); err != ErrTooLarge || mask != nil {
+		t.Errorf("over the limit: mask %v, err %v; want nil, ErrTooLarge", mask, err)
+	}
+	opt := Options{MaxPixels: area / 10, DownscaleLarge: true}
+	if _, _, err := SkinMaskChecked(src, image.NewAlpha(r), opt); err != ErrTooLarge {
+		t.Errorf("DownscaleLarge with a mask: err %v, want ErrTooLarge", err)
+	}
+
+	mask, cover, err := SkinMaskChecked(src, nil, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 640×480 over 30720 pixels is reduced by 4, to 160×120.
+	if b := mask.Bounds(); b != image.Rect(0, 0, 160, 120) {
+		t.Errorf("downscaled mask bounds %v, want (0,0)-(160,120)", b)
+	}
+	if math.Abs(cover-want) > 0.01 {
+		t.Errorf("downscaled coverage %v, want about %v", cover, want)
+	}
+}
+
+func TestSkinMaskWithMaxPixels(t *testing.T) {
+	src, _ := GenerateTestImage(1, 3)
+	r := src.Bounds()
+	area := r.Dx() * r.Dy()
+
+	if mask, cover := SkinMaskWith(src, nil, Options{MaxPixels: area - 1}); mask != nil || cover != 0 {
+		t.Errorf("over the limit: mask %v, cover %v; want nil, 0", mask, cover)
+	}
+	opt := Options{MaxPixels: area / 10, DownscaleLarge: true}
+	if mask, cover := SkinMaskWith(src, image.NewAlpha(r), opt); mask != nil || cover != 0 {
+		t.Errorf("DownscaleLarge with a mask: mask %v, cover %v; want nil, 0", mask, cover)
+	}
+	mask, cover := SkinMaskWith(src, nil, opt)
+	want, wantCover, _ := SkinMaskChecked(src, nil, opt)
+	if mask == nil || mask.Bounds() != want.Bounds() || cover != wantCover {
+		t.Errorf("downscaled: cover %v, want %v as from SkinMaskChecked", cover, wantCover)
+	}
+}
+
+func TestSkinMaskCheckedDownscaleAllocs(t *testing.T) {
+	img, _ := GenerateTestImage(2, 3)
+	src := image.NewNRGBA(img.Bounds())
+	draw.Draw(src, src.Rect, img, img.Rect.Min, draw.Src)
+	full := 4 * src.Rect.Dx() * src.Rect.Dy()
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, _, err := SkinMaskChecked(src, nil, Options{MaxPixels: 10000, DownscaleLarge: true})
+	runtime.ReadMemStats(&after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := after.TotalAlloc - before.TotalAlloc; n >= uint64(full)/4 {
+		t.Errorf("downscaling allocated %d bytes, want well under the %d of a full-size copy", n, full)
+	}
+}
+
+func TestFill(t *testing.T) {
+	src := randRGBA(image.Rect(-2, -2, 30, 20), 11)
+	want, _ := refMask(src, src.Rect)
+	for _, tc := range []struct{ fill, want int }{{0, 255}, {200, 200}, {1, 1}, {300, 255}} {
+		for _, in := range []image.Image{src, generic{src}} {
+			mask, _ := SkinMaskWith(in, nil, Options{Fill: tc.fill})
+			a := mask.(*image.Alpha)
+			for i, skin := range want {
+				v := 0
+				if skin {
+					v = tc.want
+				}
+				if int(a.Pix[i]) != v {
+					t.Fatalf("%T, Fill %d: pixel %d = %d, want %d", in, tc.fill, i, a.Pix[i], v)
+				}
+			}
+		}
+		m16, _ := SkinMaskWith(src, image.NewAlpha16(src.Rect), Options{Fill: tc.fill})
+		for i, skin := range want {
+			v := 0
+			if skin {
+				v = tc.want * 0x101
+			}
+			x, y := src.Rect.Min.X+i%src.Rect.Dx(), src.Rect.Min.Y+i/src.Rect.Dx()
+			if got := int(m16.(*image.Alpha16).Alpha16At(x, y).A); got != v {
+				t.Fatalf("Alpha16, Fill %d: pixel %d = %d, want %d", tc.fill, i, got, v)
+			}
+		}
+	}
+}
+
+// scaled returns a copy of src with each channel scaled by k,
+// clamped to 255, as an exposure change.
+func scaled(src *image.RGBA, k float64) *image.RGBA {
+	dst := image.NewRGBA(src.Rect)
+	for i, v := range src.Pix {
+		if i%4 == 3 {
+			dst.Pix[i] = v
+			continue
+		}
+		dst.Pix[i] = uint8(math.Min(255, float64(v)*k+0.5))
+	}
+	return dst
+}
+
+func TestAdaptLuminance(t *testing.T) {
+	src, _ := GenerateTestImage(5, 4)
+	_, want := SkinMask(src, nil)
+	for _, k := range []float64{0.45, 1.3} {
+		img := scaled(src, k)
+		_, plain := SkinMask(img, nil)
+		_, adapted := SkinMaskWith(img, nil, Options{AdaptLuminance: true})
+		dp, da := math.Abs(plain-want), math.Abs(adapted-want)
+		if da > dp || (k < 1 && da >= dp) || da > 0.01 {
+			t.Errorf("exposure ×%v: coverage %v plain, %v adapted; want adapted nearer the original %v", k, plain, adapted, want)
+		}
+	}
+}
+
+func TestRatioZeroGreen(t *testing.T) {
+	red := image.NewRGBA(image.Rect(0, 0, 256, 1))
+	for x := 0; x < 256; x++ {
+		red.SetRGBA(x, 0, color.RGBA{uint8(x), 0, uint8(x / 2), 255})
+		if IsSkin(uint8(x), 0, uint8(x/2)) || PassRatio(uint8(x), 0, 0) {
+			t.Fatalf("IsSkin accepts (%d, 0, %d)", x, x/2)
+		}
+	}
+	// Thresholds that pass any luminance and delta, leaving only
+	// the ratio to reject g = 0.
+	loose := Options{Thresholds: Thresholds{MaxRGDelta: 255, MaxRGRatio: 1e9}}
+	for _, src := range []image.Image{red, atNRGBA(red), generic{red}} {
+		if _, cover := SkinMask(src, nil); cover != 0 {
+			t.Errorf("SkinMask of %T: coverage %v, want 0", src, cover)
+		}
+		if _, cover := SkinMaskWith(src, nil, loose); cover != 0 {
+			t.Errorf("SkinMaskWith of %T, loose thresholds: coverage %v, want 0", src, cover)
+		}
+	}
+	if cover := SkinMaskRGBA(red, image.NewAlpha(red.Rect)); cover != 0 {
+		t.Errorf("SkinMaskRGBA: coverage %v, want 0", cover)
+	}
+
+	// With g = 1 the same thresholds accept all but black.
+	for i := 1; i < len(red.Pix); i += 4 {
+		red.Pix[i] = 1
+	}
+	if _, cover := SkinMaskWith(red, nil, loose); cover != 255.0/256 {
+		t.Errorf("g = 1, loose thresholds: coverage %v, want %v", cover, 255.0/256)
+	}
+}
+
+// atNRGBA returns a copy of src as an *image.NRGBA.
+func atNRGBA(src image.Image) *image.NRGBA {
+	r := src.Bounds()
+	dst := image.NewNRGBA(r)
+	draw.Draw(dst, r, src, r.Min, draw.Src)
+	return dst
+}
+
+func TestAsConstraint(t *testing.T) {
+	r := image.Rect(-3, -2, 50, 40)
+	rgba := randRGBA(r, 14)
+	ycc := randYCbCr(r, image.YCbCrSubsampleRatio444, 14)
+	cmyk := image.NewCMYK(r)
+	randSkinish(cmyk, rand.New(rand.NewSource(14)))
+	// The region of interest, a disk, within the mask bounds mr.
+	mr := image.Rect(0, 0, 45, 38)
+	roi := func(x, y int) bool { return (x-22)*(x-22)+(y-19)*(y-19) < 15*15 }
+
+	for _, tt := range []struct {
+		name string
+		src  image.Image
+		mask func() draw.Image
+		opt  Options
+	}{
+		{"RGBA", rgba, func() draw.Image { return image.NewAlpha(mr) }, Options{}},
+		{"RGBA into RGBA", rgba, func() draw.Image { return image.NewRGBA(mr) }, Options{}},
+		{"NRGBA", atNRGBA(rgba), func() draw.Image { return image.NewAlpha(mr) }, Options{}},
+		{"Gray", image.NewGray(r), func() draw.Image { return image.NewAlpha(mr) }, Options{}},
+		{"CMYK", cmyk, func() draw.Image { return image.NewAlpha(mr) }, Options{}},
+		{"YCbCr", ycc, func() draw.Image { return image.NewAlpha(mr) }, Options{}},
+		{"YCbCr native", ycc, func() draw.Image { return image.NewAlpha(mr) }, Options{Mode: ModeYCbCr}},
+		{"generic", generic{rgba}, func() draw.Image { return image.NewAlpha(mr) }, Options{}},
+		{"Alpha16", rgba, func() draw.Image { return image.NewAlpha16(mr) }, Options{}},
+		{"Gray mask", rgba, func() draw.Image { return image.NewGray(mr) }, Options{}},
+		{"Set", rgba, func() draw.Image { return setMask{image.NewAlpha(mr)} }, Options{}},
+		{"supersampled", rgba, func() draw.Image { return image.NewAlpha(mr) }, Options{Supersample: true}},
+	} {
+		mask := tt.mask()
+		area := 0
+		for y := mr.Min.Y; y < mr.Max.Y; y++ {
+			for x := mr.Min.X; x < mr.Max.X; x++ {
+				if roi(x, y) {
+					mask.Set(x, y, color.White)
+					area++
+				}
+			}
+		}
+		// Detection into a fresh mask, which the constrained one
+		// must match within the region.
+		ref, _ := SkinMaskWith(tt.src, tt.mask(), tt.opt)
+		tt.opt.AsConstraint = true
+		_, cover := SkinMaskWith(tt.src, mask, tt.opt)
+
+		m, quarters := 0, 0
+		for y := mr.Min.Y; y < mr.Max.Y; y++ {
+			for x := mr.Min.X; x < mr.Max.X; x++ {
+				v, _, _, _ := mask.At(x, y).RGBA()
+				if !roi(x, y) {
+					if v != 0 {
+						t.Fatalf("%s: (%d, %d) outside the region set to %#x", tt.name, x, y, v)
+					}
+					continue
+				}
+				if w, _, _, _ := ref.At(x, y).RGBA(); v != w {
+					t.Fatalf("%s: (%d, %d) in the region is %#x, want %#x", tt.name, x, y, v, w)
+				}
+				if v == 0xffff {
+					m++
+				}
+				quarters += int(v>>8+32) / 64 // supersampled points found
+			}
+		}
+		want := coverage(m, area)
+		if tt.opt.Supersample {
+			want = coverage(quarters, 4*area)
+		}
+		if cover != want || tt.name != "Gray" && cover == 0 {
+			t.Errorf("%s: coverage %v, want %v of the region's %d pixels", tt.name, cover, want, area)
+		}
+	}
+}
+
+func TestLargestOnly(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 80, 60))
+	paint(src, src.Rect, testBg)
+	paint(src, image.Rect(5, 5, 25, 35), testSkin)   // 600 pixels
+	paint(src, image.Rect(40, 10, 50, 20), testSkin) // 100 pixels
+
+	_, all := SkinMask(src, nil)
+	_, big := SkinMaskWith(src, nil, Options{LargestOnly: true})
+	if all != 700.0/4800 || big != 600.0/4800 {
+		t.Errorf("coverage %v, largest only %v; want %v, %v", all, big, 700.0/4800, 600.0/4800)
+	}
+
+	// Constrained to the right half, where the smaller region is the
+	// largest, relative to the half's area.
+	mask := image.NewAlpha(src.Rect)
+	paint(mask, image.Rect(30, 0, 80, 60), color.Opaque)
+	_, half := SkinMaskWith(src, mask, Options{LargestOnly: true, AsConstraint: true})
+	if half != 100.0/3000 {
+		t.Errorf("constrained, largest only: coverage %v, want %v", half, 100.0/3000)
+	}
+
+	if _, none := SkinMaskWith(image.NewRGBA(src.Rect), nil, Options{LargestOnly: true}); none != 0 {
+		t.Errorf("no skin, largest only: coverage %v, want 0", none)
+	}
+}
@@ -0,0 +1,72 @@
+package face
+
+import (
+	"image"
+	"image/draw"
+	"runtime"
+	"sync"
+)
+
+// SkinMaskN is like SkinMask, but splits the image into workers
+// horizontal strips processed concurrently. The mask and coverage
+// are identical to SkinMask's: strips don't overlap and their pixel
+// counts are summed in strip order once every strip is done.
+//
+// A workers of 0 or less selects runtime.GOMAXPROCS(0), so the
+// strips match the processors available. Both src and mask must
+// support SubImage, as the standard image types do; otherwise, or if
+// workers is 1, the work is done serially.
+func SkinMaskN(src image.Image, mask draw.Image, workers int) (mask0 draw.Image, cover float64) {
+	mask, m, n := skinMaskN(src, mask, &Options{}, workers)
+	return mask, coverage(m, n)
+}
+
+type subImager interface {
+	SubImage(r image.Rectangle) image.Image
+}
+
+func skinMaskN(src image.Image, mask draw.Image, opt *Options, workers int) (mask0 draw.Image, m, n int) {
+	if mask == nil {
+		mask = image.NewAlpha(src.Bounds())
+	}
+	ss, ok1 := src.(subImager)
+	ms, ok2 := mask.(subImager)
+	r := mask.Bounds()
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > r.Dy() {
+		workers = r.Dy()
+	}
+	if !ok1 || !ok2 || workers < 2 {
+		return skinMask(src, mask, opt)
+	}
+
+	// The classifier is chosen once for the whole of src, so
+	// luminance adaptation does not vary by strip.
+	o := opt.fixed(src)
+	h := (r.Dy() + workers - 1) / workers
+	strips := make([]draw.Image, 0, workers)
+	for y := r.Min.Y; y < r.Max.Y; y += h {
+		sm, ok := ms.SubImage(image.Rect(r.Min.X, y, r.Max.X, y+h).Intersect(r)).(draw.Image)
+		if !ok {
+			return skinMask(src, mask, opt)
+		}
+		strips = append(strips, sm)
+	}
+	count := make([][2]int, len(strips))
+	var wg sync.WaitGroup
+	for i, sm := range strips {
+		wg.Add(1)
+		go func(i int, sm draw.Image) {
+			defer wg.Done()
+			_, count[i][0], count[i][1] = skinMask(ss.SubImage(sm.Bounds()), sm, &o)
+		}(i, sm)
+	}
+	wg.Wait()
+	for _, c := range count {
+		m += c[0]
+		n += c[1]
+	}
+	return mask, m, n
+}
@@ -0,0 +1,20 @@
+package face
+
+import (
+	"bytes"
+	"image"
+	"testing"
+)
+
+func TestSkinMaskNDeterministic(t *testing.T) {
+	src := randRGBA(image.Rect(-7, 3, 193, 153), 5)
+	ref, cover := SkinMask(src, nil)
+	want := ref.(*image.Alpha).Pix
+	for i := 0; i < 100; i++ {
+		workers := []int{0, 1, 3, 7, 64}[i%5]
+		mask, c := SkinMaskN(src, nil, workers)
+		if c != cover || !bytes.Equal(mask.(*image.Alpha).Pix, want) {
+			t.Fatalf("run %d, %d workers: coverage %v, want %v, or mask differs", i, workers, c, cover)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package face
+
+import (
+	"image"
+	"math/rand"
+)
+
+// SkinPoints returns the coordinates of every nonzero pixel of mask
+// in row-major order.
+func SkinPoints(mask *image.Alpha) []image.Point {
+	var pts []image.Point
+	r := mask.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := mask.Pix[mask.PixOffset(r.Min.X, y):][:r.Dx()]
+		for x, a := range row {
+			if a != 0 {
+				pts = append(pts, image.Pt(r.Min.X+x, y))
+			}
+		}
+	}
+	return pts
+}
+
+// SkinPointsSampled returns up to n coordinates of nonzero pixels of
+// mask, chosen uniformly at random by reservoir sampling in a single
+// pass. The choice is seeded, so the same mask yields the same
+// points. The points are not in any particular order.
+func SkinPointsSampled(mask *image.Alpha, n int) []image.Point {
+	if n <= 0 {
+		return nil
+	}
+	rnd := rand.New(rand.NewSource(1))
+	pts := make([]image.Point, 0, n)
+	seen := 0
+	r := mask.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		row := mask.Pix[mask.PixOffset(r.Min.X, y):][:r.Dx()]
+		for x, a := range row {
+			if a == 0 {
+				continue
+			}
+			seen++
+			if len(pts) < n {
+				pts = append(pts, image.Pt(r.Min.X+x, y))
+			} else if i := rnd.Intn(seen); i < n {
+				pts[i] = image.Pt(r.Min.X+x, y)
+			}
+		}
+	}
+	return pts
+}
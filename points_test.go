@@ -0,0 +1,54 @@
+package face
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestSkinPoints(t *testing.T) {
+	src := randRGBA(image.Rect(-6, 3, 34, 43), 18)
+	m, _ := SkinMask(src, nil)
+	mask := m.(*image.Alpha)
+	var want []image.Point
+	for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+		for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+			if mask.AlphaAt(x, y).A != 0 {
+				want = append(want, image.Pt(x, y))
+			}
+		}
+	}
+	if got := SkinPoints(mask); !reflect.DeepEqual(got, want) {
+		t.Errorf("SkinPoints returned %d points, want the %d in row-major order", len(got), len(want))
+	}
+	if got := SkinPoints(image.NewAlpha(mask.Rect)); got != nil {
+		t.Errorf("empty mask: %v, want nil", got)
+	}
+
+	set := make(map[image.Point]bool)
+	for _, p := range want {
+		set[p] = true
+	}
+	for _, n := range []int{1, 10, len(want), len(want) + 5} {
+		got := SkinPointsSampled(mask, n)
+		if len(got) != n && (n <= len(want) || len(got) != len(want)) {
+			t.Errorf("n = %d: %d points", n, len(got))
+		}
+		seen := make(map[image.Point]bool)
+		for _, p := range got {
+			if !set[p] || seen[p] {
+				t.Fatalf("n = %d: %v is not a distinct skin point", n, p)
+			}
+			seen[p] = true
+		}
+		if again := SkinPointsSampled(mask, n); !reflect.DeepEqual(again, got) {
+			t.Errorf("n = %d: a second call chose other points", n)
+		}
+	}
+	if got := SkinPointsSampled(mask, 10); reflect.DeepEqual(got, want[:10]) {
+		t.Error("n = 10: the first points were returned, not a sample")
+	}
+	if got := SkinPointsSampled(mask, 0); got != nil {
+		t.Errorf("n = 0: %v, want nil", got)
+	}
+}
@@ -0,0 +1,52 @@
+package face
+
+import (
+	"image"
+	"math/bits"
+	"sync"
+)
+
+// alphaPools hold released masks by size class: pool k holds masks
+// whose pixels have a capacity of 1<<k bytes.
+var alphaPools [bits.UintSize]sync.Pool
+
+// SkinMaskPooled is like SkinMask with a nil mask, but takes the mask
+// from a pool shared by all goroutines, cutting allocation in servers
+// detecting skin at a high rate. The returned release func puts the
+// mask back; the mask must not be used after release is called, and
+// release must be called at most once. A mask never released is
+// simply collected.
+func SkinMaskPooled(src image.Image) (mask *image.Alpha, release func(), cover float64) {
+	mask = getAlpha(src.Bounds())
+	_, cover = skinMaskColor(src, mask, &Options{})
+	return mask, func() { putAlpha(mask) }, cover
+}
+
+// getAlpha returns a cleared mask with bounds r from the pools,
+// allocating one if they have none of its size class.
+func getAlpha(r image.Rectangle) *image.Alpha {
+	n := r.Dx() * r.Dy()
+	k := 0
+	if n > 1 {
+		k = bits.Len(uint(n - 1))
+	}
+	a, _ := alphaPools[k].Get().(*image.Alpha)
+	if a == nil {
+		a = &image.Alpha{Pix: make([]uint8, 1<<k)}
+	}
+	pix := a.Pix[:n]
+	for i := range pix {
+		pix[i] = 0
+	}
+	*a = image.Alpha{Pix: pix, Stride: r.Dx(), Rect: r}
+	return a
+}
+
+// putAlpha returns a mask from getAlpha to the pools.
+func putAlpha(a *image.Alpha) {
+	k := bits.Len(uint(cap(a.Pix) - 1))
+	if cap(a.Pix) != 1<<k {
+		return
+	}
+	alphaPools[k].Put(a)
+}
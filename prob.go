@@ -0,0 +1,43 @@
+package face
+
+import (
+	"image"
+	"math"
+)
+
+// SkinProbabilityRGBA writes to dst how deeply each pixel of src sits
+// inside the skin band of SkinMask. Pixels that are not skin are 0.
+// Skin pixels range from 128 at the edge of the band to 255 at its
+// center, the depth being the lesser of the normalized distances to
+// the edges of r−g, in [20, 90], and r/g, in [1, 2.5]. Only the
+// intersection of the bounds of src and dst is written.
+func SkinProbabilityRGBA(src *image.RGBA, dst *image.Gray) {
+	r := src.Bounds().Intersect(dst.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp, dp := src.PixOffset(r.Min.X, y), dst.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x, sp, dp = x+1, sp+4, dp+1 {
+			p := src.Pix[sp : sp+3 : sp+3]
+			if skinRGB(p[0], p[1], p[2]) {
+				dst.Pix[dp] = confidence(p[0], p[1])
+			} else {
+				dst.Pix[dp] = 0
+			}
+		}
+	}
+}
+
+// confidence rates the depth of r and g inside the skin band as in
+// SkinProbabilityRGBA. Colors outside the band rate 128.
+func confidence(r, g uint8) uint8 {
+	const (
+		midDelta, halfDelta = 55, 35
+		midRatio, halfRatio = 1.75, 0.75
+	)
+	d := math.Abs(float64(int(r)-int(g))-midDelta) / halfDelta
+	if g == 0 {
+		d = 1
+	} else if q := math.Abs(float64(r)/float64(g)-midRatio) / halfRatio; q > d {
+		d = q
+	}
+	return 128 + uint8(127*clamp01(1-d)+0.5)
+}
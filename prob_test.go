@@ -0,0 +1,40 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestSkinProbabilityRGBA(t *testing.T) {
+	src := randRGBA(image.Rect(0, 0, 40, 30), 19)
+	center := image.Rect(0, 0, 4, 4)
+	paint(src, center, color.RGBA{128, 73, 60, 255}) // r−g 55, r/g about 1.75
+	edge := image.Rect(4, 0, 8, 4)
+	paint(src, edge, color.RGBA{120, 99, 60, 255}) // r−g 21
+	dst := image.NewGray(image.Rect(-5, -5, 45, 35))
+	paint(dst, dst.Rect, color.Gray{77})
+	SkinProbabilityRGBA(src, dst)
+
+	m, _ := SkinMask(src, nil)
+	for y := dst.Rect.Min.Y; y < dst.Rect.Max.Y; y++ {
+		for x := dst.Rect.Min.X; x < dst.Rect.Max.X; x++ {
+			v := dst.GrayAt(x, y).Y
+			p := image.Pt(x, y)
+			switch {
+			case !p.In(src.Rect):
+				if v != 77 {
+					t.Fatalf("(%d, %d) outside src written: %d", x, y, v)
+				}
+			case p.In(center) && v < 250:
+				t.Fatalf("(%d, %d) at the center of the band is %d, want nearly 255", x, y, v)
+			case p.In(edge) && (v < 128 || v > 140):
+				t.Fatalf("(%d, %d) at the edge of the band is %d, want just over 128", x, y, v)
+			case isSet(m, x, y) != (v != 0):
+				t.Fatalf("(%d, %d) is %d where SkinMask is %v", x, y, v, isSet(m, x, y))
+			case v != 0 && v < 128:
+				t.Fatalf("(%d, %d) is skin of probability %d, want at least 128", x, y, v)
+			}
+		}
+	}
+}
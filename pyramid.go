@@ -0,0 +1,90 @@
+package face
+
+import (
+	"image"
+)
+
+// PyramidCoverage detects skin in src and in up to levels-1
+// successive 2× downscales of it, and returns a coverage that
+// favors pixels found at several scales. Each pixel of src is
+// looked up in every level's mask; if it is skin in k of the L
+// levels, it contributes (k/L)² to the coverage, so a pixel that is
+// skin at every scale counts fully and one seen at a single scale
+// of four counts 1/16. Speckle that vanishes when downscaled is
+// thereby discounted.
+//
+// A levels less than 1 is treated as 1, which is plain SkinMask
+// coverage. The pyramid stops early once a level is a single pixel.
+func PyramidCoverage(src image.Image, levels int) float64 {
+	r := src.Bounds()
+	if r.Empty() {
+		return 0
+	}
+	if levels < 1 {
+		levels = 1
+	}
+	img := toRGBA(src)
+	masks := make([]*image.Alpha, 0, levels)
+	for {
+		m, _ := skinMaskColor(img, nil, &Options{})
+		masks = append(masks, m.(*image.Alpha))
+		if len(masks) == levels || img.Bounds().Dx() == 1 && img.Bounds().Dy() == 1 {
+			break
+		}
+		img = downscale(img)
+	}
+
+	L := len(masks)
+	sum := 0
+	for y := 0; y < r.Dy(); y++ {
+		for x := 0; x < r.Dx(); x++ {
+			k := 0
+			for i, m := range masks {
+				if m.Pix[m.PixOffset(m.Rect.Min.X+x>>i, m.Rect.Min.Y+y>>i)] != 0 {
+					k++
+				}
+			}
+			sum += k * k
+		}
+	}
+	return float64(sum) / float64(L*L*r.Dx()*r.Dy())
+}
+
+// DetectRefine finds skin in src in two passes: a coarse one over
+// src box-averaged by a power of two until it is at most 128 pixels
+// on a side, which locates the largest skin region, then a full one
+// within that region's bounds, scaled back and padded on each side
+// by an eighth of their width horizontally and of their height
+// vertically. It returns the refined region in the coordinates of
+// src, its mask and the coverage within it. The coarse level is read
+// straight from src, and the full pass touches only the region, so
+// on a large image most pixels are read once. If the coarse pass
+// finds no skin, the region is empty and the mask nil.
+func DetectRefine(src image.Image) (image.Rectangle, *image.Alpha, float64) {
+	const (
+		coarse = 128
+	)
+	r := src.Bounds()
+	shift := 0
+	for w, h := r.Dx(), r.Dy(); w > coarse || h > coarse; w, h = (w+1)/2, (h+1)/2 {
+		shift++
+	}
+	img := shrink(src, 1<<shift)
+	m, _ := skinMaskColor(img, nil, &Options{})
+	c, ok := largest(Components(m.(*image.Alpha), Conn8))
+	if !ok {
+		return image.Rectangle{}, nil, 0
+	}
+	b := c.Bounds
+	b = image.Rect(b.Min.X<<shift, b.Min.Y<<shift, b.Max.X<<shift, b.Max.Y<<shift)
+	px, py := b.Dx()/8, b.Dy()/8
+	b = image.Rect(b.Min.X-px, b.Min.Y-py, b.Max.X+px, b.Max.Y+py).Add(r.Min).Intersect(r)
+
+	sub := src
+	if s, ok := src.(subImager); ok {
+		sub = s.SubImage(b)
+	}
+	mask := image.NewAlpha(b)
+	_, cover := skinMaskColor(sub, mask, &Options{})
+	return b, mask, cover
+}
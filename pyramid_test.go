@@ -0,0 +1,60 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+)
+
+func TestDetectRefine(t *testing.T) {
+	img, faces := GenerateTestImage(9, 1)
+	face := faces[0]
+	// The same image at a negative origin, and as YCbCr.
+	shifted := atRGBA(img)
+	shifted.Rect = shifted.Rect.Sub(image.Pt(300, 200))
+	ycc := randYCbCr(img.Rect, image.YCbCrSubsampleRatio444, 0)
+	for y := 0; y < 480; y++ {
+		for x := 0; x < 640; x++ {
+			p := img.RGBAAt(x, y)
+			i := ycc.YOffset(x, y)
+			ycc.Y[i], ycc.Cb[i], ycc.Cr[i] = color.RGBToYCbCr(p.R, p.G, p.B)
+		}
+	}
+	for _, src := range []image.Image{img, shifted, ycc} {
+		off := src.Bounds().Min
+		r, mask, cover := DetectRefine(src)
+		f := face.Add(off)
+		// The coarse bounds are a multiple of 8 pixels, those of the
+		// 640×480 source downscaled to 80×60, padded by an eighth of
+		// their width and height.
+		px, py := (f.Dx()+8)/8, (f.Dy()+8)/8
+		outer := image.Rect(f.Min.X-8-px, f.Min.Y-8-py, f.Max.X+8+px, f.Max.Y+8+py)
+		if !r.In(outer) {
+			t.Errorf("%T at %v: region %v, want within %v around %v", src, off, r, outer, f)
+			continue
+		}
+		// Coarse blocks on the edge of the face average in too much
+		// background to pass, so its outline may be clipped; here 20
+		// of 2671 pixels.
+		all, _ := SkinMask(src, nil)
+		in, out := countSet(all, func(x, y int) bool { return image.Pt(x, y).In(r) })
+		if out*50 > in+out {
+			t.Errorf("%T at %v: region %v holds %d of %d skin pixels", src, off, r, in, in+out)
+		}
+		want, wc := SkinMask(src, image.NewAlpha(r))
+		if cover != wc || !equalAlpha(mask, want.(*image.Alpha)) {
+			t.Errorf("%T at %v: mask and coverage %v differ from SkinMask's %v", src, off, cover, wc)
+		}
+	}
+
+	// The coarse level is read from the source, not a full copy.
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	DetectRefine(ycc)
+	runtime.ReadMemStats(&after)
+	if n := after.TotalAlloc - before.TotalAlloc; n >= 4*640*480 {
+		t.Errorf("DetectRefine of a YCbCr source allocated %d bytes", n)
+	}
+}
@@ -0,0 +1,526 @@
+package face
+
+import (
+	"image"
+	"math"
+)
+
+// Component is a connected region of nonzero mask pixels.
+type Component struct {
+	Bounds   image.Rectangle // smallest rectangle containing the region
+	Area     int             // number of pixels in the region
+	Centroid image.Point     // mean pixel position, rounded down
+
+	// Perimeter counts the pixels of the region with a 4-connected
+	// neighbor outside it or outside the mask.
+	Perimeter int
+}
+
+// Connectivity selects which of its neighbors a pixel is connected
+// to. The zero value is Conn8.
+type Connectivity int
+
+const (
+	Conn8 Connectivity = iota // edge and corner neighbors
+	Conn4                     // edge neighbors only
+)
+
+var (
+	conn4 = []image.Point{{0, -1}, {-1, 0}, {1, 0}, {0, 1}}
+	conn8 = []image.Point{{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}}
+)
+
+func (c Connectivity) neighbors() []image.Point {
+	if c == Conn4 {
+		return conn4
+	}
+	return conn8
+}
+
+// complement returns the connectivity of the background of a
+// region with connectivity c, so that regions and holes do not
+// cross each other diagonally.
+func (c Connectivity) complement() Connectivity {
+	if c == Conn4 {
+		return Conn8
+	}
+	return Conn4
+}
+
+// Components labels the connected regions of nonzero pixels in mask
+// and returns them in the order their first pixel appears in a
+// row-major scan.
+func Components(mask *image.Alpha, conn Connectivity) []Component {
+	_, cs := label(mask, conn)
+	return cs
+}
+
+// RegionCount returns the number of connected regions of nonzero
+// pixels in mask.
+func RegionCount(mask *image.Alpha, conn Connectivity) int {
+	return len(Components(mask, conn))
+}
+
+// FloodFill sets the pixel at seed, and every pixel connected to it
+// with the same value, to v, in place. It returns the number of pixels
+// changed, which is zero if seed lies outside mask or already has
+// the value v.
+func FloodFill(mask *image.Alpha, seed image.Point, v uint8, conn Connectivity) int {
+	r := mask.Bounds()
+	if !seed.In(r) {
+		return 0
+	}
+	old := mask.Pix[mask.PixOffset(seed.X, seed.Y)]
+	if old == v {
+		return 0
+	}
+	n := 0
+	at := pixAt(mask)
+	flood(r.Dx(), r.Dy(), conn, (seed.Y-r.Min.Y)*r.Dx()+seed.X-r.Min.X, nil,
+		func(j int) bool { return mask.Pix[at(j)] == old },
+		func(j int) { mask.Pix[at(j)] = v; n++ },
+	)
+	return n
+}
+
+// FillHoles sets to 255, in place, every zero pixel of mask that is
+// not connected to the border through other zero pixels, and returns
+// how many it set. Holes are traced with the complement of conn, so
+// a Conn8 region is closed by diagonal steps and a Conn4 region is
+// not.
+func FillHoles(mask *image.Alpha, conn Connectivity) int {
+	r := mask.Bounds()
+	w, h := r.Dx(), r.Dy()
+	at := pixAt(mask)
+	seen := make([]bool, w*h)
+	in := func(j int) bool { return !seen[j] && mask.Pix[at(j)] == 0 }
+	visit := func(j int) { seen[j] = true }
+	var stack []int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if y != 0 && y != h-1 && x != 0 && x != w-1 {
+				continue
+			}
+			if j := y*w + x; in(j) {
+				stack = flood(w, h, conn.complement(), j, stack, in, visit)
+			}
+		}
+	}
+	n := 0
+	for j := range seen {
+		if p := at(j); !seen[j] && mask.Pix[p] == 0 {
+			mask.Pix[p] = 255
+			n++
+		}
+	}
+	return n
+}
+
+// LooseCoverage returns the area of the largest component of mask
+// divided by the area of its bounding rectangle. Faces are compact
+// and score high; a skin-toned wall or beach filling the frame with
+// one diffuse blob scores low. It returns 0 for an empty mask.
+func LooseCoverage(mask *image.Alpha) float64 {
+	c, ok := largest(Components(mask, Conn8))
+	if !ok {
+		return 0
+	}
+	return float64(c.Area) / float64(c.Bounds.Dx()*c.Bounds.Dy())
+}
+
+// FramingRatio returns the area of the smallest rectangle holding
+// every nonzero pixel of mask divided by the area of mask, telling a
+// subject that fills the frame from a speck within it. It is never
+// less than the coverage; a low coverage with a high ratio is a
+// subject spread across the frame. It returns 0 for an empty mask.
+func FramingRatio(mask *image.Alpha) float64 {
+	r := mask.Bounds()
+	var b image.Rectangle
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		mp := mask.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x, mp = x+1, mp+1 {
+			if mask.Pix[mp] != 0 {
+				b = b.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	return coverage(b.Dx()*b.Dy(), r.Dx()*r.Dy())
+}
+
+// Coverage returns the fraction of the pixels of mask whose alpha is
+// at least minAlpha, reading back the coverage of a soft or external
+// mask at a chosen cutoff. A minAlpha of 0 counts every pixel.
+func Coverage(mask *image.Alpha, minAlpha uint8) float64 {
+	r := mask.Bounds()
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		mp := mask.PixOffset(r.Min.X, y)
+		for _, v := range mask.Pix[mp : mp+r.Dx()] {
+			if v >= minAlpha {
+				n++
+			}
+		}
+	}
+	return coverage(n, r.Dx()*r.Dy())
+}
+
+// CoverageWeighted returns the fraction of the total weight of
+// weight that falls on nonzero pixels of mask, so that skin where
+// weight is high, such as near the center of a frame under a
+// center-biased prior, counts for more. It returns 0 if the weights
+// sum to 0. The bounds of mask and weight must be equal.
+func CoverageWeighted(mask *image.Alpha, weight *image.Gray) float64 {
+	r := mask.Bounds()
+	if !weight.Bounds().Eq(r) {
+		panic("face: CoverageWeighted: mask and weight bounds differ")
+	}
+	on, all := 0, 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		mp, wp := mask.PixOffset(r.Min.X, y), weight.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x, mp, wp = x+1, mp+1, wp+1 {
+			v := int(weight.Pix[wp])
+			all += v
+			if mask.Pix[mp] != 0 {
+				on += v
+			}
+		}
+	}
+	return coverage(on, all)
+}
+
+// largest returns the component with the greatest area.
+func largest(cs []Component) (c Component, ok bool) {
+	for _, v := range cs {
+		if v.Area > c.Area {
+			c, ok = v, true
+		}
+	}
+	return c, ok
+}
+
+// pixAt returns a function mapping the index of a pixel in a
+// tightly packed copy of mask to its offset in mask.Pix.
+func pixAt(mask *image.Alpha) func(j int) int {
+	r := mask.Bounds()
+	w := r.Dx()
+	if w == 0 {
+		return func(int) int { return 0 }
+	}
+	return func(j int) int {
+		return (j/w)*mask.Stride + j%w + mask.PixOffset(r.Min.X, r.Min.Y)
+	}
+}
+
+// flood visits the pixel seed of a w×h grid and every pixel
+// connected to it for which in reports true. Pixels are indexed in
+// row-major order. Visit must make in report false for the pixels
+// it is given. The seed is visited without consulting in. Flood
+// returns its work stack for reuse.
+func flood(w, h int, conn Connectivity, seed int, stack []int, in func(j int) bool, visit func(j int)) []int {
+	nb := conn.neighbors()
+	visit(seed)
+	stack = append(stack[:0], seed)
+	for len(stack) > 0 {
+		i := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		px, py := i%w, i/w
+		for _, d := range nb {
+			nx, ny := px+d.X, py+d.Y
+			if nx < 0 || ny < 0 || nx >= w || ny >= h {
+				continue
+			}
+			if j := ny*w + nx; in(j) {
+				visit(j)
+				stack = append(stack, j)
+			}
+		}
+	}
+	return stack
+}
+
+// label assigns every nonzero pixel of mask a component number
+// starting at 1 and returns the labels, indexed like the pixels of
+// a tightly packed mask, and the components they number.
+func label(mask *image.Alpha, conn Connectivity) (labels []int32, cs []Component) {
+	var s scratch
+	return s.label(mask, conn)
+}
+
+// scratch holds the buffers of label for reuse across calls.
+type scratch struct {
+	labels []int32
+	stack  []int
+	cs     []Component
+}
+
+// label is the package-level label, reusing the buffers of s. The
+// results are valid until the next call.
+func (s *scratch) label(mask *image.Alpha, conn Connectivity) (labels []int32, cs []Component) {
+	r := mask.Bounds()
+	w, h := r.Dx(), r.Dy()
+	at := pixAt(mask)
+	if cap(s.labels) < w*h {
+		s.labels = make([]int32, w*h)
+	}
+	labels = s.labels[:w*h]
+	for j := range labels {
+		labels[j] = 0
+	}
+	cs = s.cs[:0]
+	stack := s.stack[:0]
+	var (
+		id     int32
+		c      Component
+		sx, sy int
+	)
+	in := func(j int) bool { return labels[j] == 0 && mask.Pix[at(j)] != 0 }
+	visit := func(j int) {
+		labels[j] = id
+		x, y := j%w, j/w
+		c.Area++
+		sx += x
+		sy += y
+		if x == 0 || y == 0 || x == w-1 || y == h-1 ||
+			mask.Pix[at(j-1)] == 0 || mask.Pix[at(j+1)] == 0 || mask.Pix[at(j-w)] == 0 || mask.Pix[at(j+w)] == 0 {
+			c.Perimeter++
+		}
+		c.Bounds = c.Bounds.Union(image.Rect(x, y, x+1, y+1))
+	}
+	for j := range labels {
+		if !in(j) {
+			continue
+		}
+		id = int32(len(cs) + 1)
+		c, sx, sy = Component{}, 0, 0
+		stack = flood(w, h, conn, j, stack, in, visit)
+		c.Bounds = c.Bounds.Add(r.Min)
+		c.Centroid = image.Pt(sx/c.Area, sy/c.Area).Add(r.Min)
+		cs = append(cs, c)
+	}
+	s.stack, s.cs = stack, cs
+	return labels, cs
+}
+
+// FitEllipse fits an ellipse to the largest component of mask using
+// its second-order central moments. The major semi-axis rx lies at
+// angle theta, in radians clockwise from the x axis (y points down),
+// and ry is the minor semi-axis. The axes are scaled so a solid
+// ellipse is fitted exactly. It returns ok == false for an empty
+// mask.
+func FitEllipse(mask *image.Alpha) (center image.Point, rx, ry, theta float64, ok bool) {
+	m, ok := largestMoments(mask)
+	if !ok {
+		return center, 0, 0, 0, false
+	}
+	l1, l2, theta := m.axes()
+	center = image.Pt(int(math.Floor(m.cx)), int(math.Floor(m.cy)))
+	return center, 2 * math.Sqrt(l1), 2 * math.Sqrt(l2), theta, true
+}
+
+// Moments returns the shape of the largest component of mask from
+// its second-order central moments, in one pass: the centroid cx, cy
+// to subpixel accuracy, with pixels positioned by their centers, the
+// angle theta of the principal axis as in FitEllipse, and the
+// eccentricity of the fitted ellipse, 0 for a disk and approaching 1
+// for a line. It returns ok == false for an empty mask.
+func Moments(mask *image.Alpha) (cx, cy, theta, eccentricity float64, ok bool) {
+	m, ok := largestMoments(mask)
+	if !ok {
+		return 0, 0, 0, 0, false
+	}
+	l1, l2, theta := m.axes()
+	if l1 > 0 {
+		eccentricity = math.Sqrt(1 - l2/l1)
+	}
+	return m.cx, m.cy, theta, eccentricity, true
+}
+
+// moments are the centroid and normalized second-order central
+// moments of a set of pixels. Pixels are unit squares positioned by
+// their centers.
+type moments struct {
+	n                int
+	cx, cy           float64
+	mu20, mu02, mu11 float64
+}
+
+// axes returns the variances along the principal axes, largest
+// first, and the angle of the first axis.
+func (m moments) axes() (l1, l2, theta float64) {
+	a, c := (m.mu20+m.mu02)/2, math.Hypot((m.mu20-m.mu02)/2, m.mu11)
+	l2 = a - c
+	if l2 < 0 {
+		l2 = 0
+	}
+	return a + c, l2, math.Atan2(2*m.mu11, m.mu20-m.mu02) / 2
+}
+
+// largestMoments computes the moments of the largest component of
+// mask.
+func largestMoments(mask *image.Alpha) (m moments, ok bool) {
+	labels, cs := label(mask, Conn8)
+	id := largestID(cs)
+	if id == 0 {
+		return m, false
+	}
+	best := cs[id-1].Area
+	r := mask.Bounds()
+	w := r.Dx()
+	var sx, sy float64
+	for i, l := range labels {
+		if l == id {
+			sx += float64(i%w) + 0.5
+			sy += float64(i/w) + 0.5
+		}
+	}
+	n := float64(best)
+	m.n, m.cx, m.cy = best, sx/n, sy/n
+	for i, l := range labels {
+		if l == id {
+			dx, dy := float64(i%w)+0.5-m.cx, float64(i/w)+0.5-m.cy
+			m.mu20 += dx * dx
+			m.mu02 += dy * dy
+			m.mu11 += dx * dy
+		}
+	}
+	m.mu20 /= n
+	m.mu02 /= n
+	m.mu11 /= n
+	m.cx += float64(r.Min.X)
+	m.cy += float64(r.Min.Y)
+	return m, true
+}
+
+// GrowFromSeed grows a region of src from seed, like a magic wand:
+// 4-connected neighbors join the region while their color is within
+// tolerance, the Euclidean distance in 8-bit RGB, of the running mean
+// color of the region. The result is a single coherent region that
+// excludes skin not connected to it. If seed is outside src, the
+// region grows instead from the densest skin pixel, the one with the
+// most skin, as classified by SkinMask, in the 15×15 window centered
+// on it; if src has no skin, the mask is empty. Growth stops at
+// 1<<22 pixels.
+func GrowFromSeed(src image.Image, seed image.Point, tolerance float64) *image.Alpha {
+	const (
+		maxArea = 1 << 22
+	)
+	img := toRGBA(src)
+	r := img.Bounds()
+	mask := image.NewAlpha(r)
+	if !seed.In(r) {
+		m, _ := skinMaskColor(img, nil, &Options{})
+		p, ok := densest(m.(*image.Alpha), 15)
+		if !ok {
+			return mask
+		}
+		seed = p
+	}
+	w, h := r.Dx(), r.Dy()
+	px := func(j int) []uint8 {
+		i := img.PixOffset(r.Min.X+j%w, r.Min.Y+j/w)
+		return img.Pix[i : i+3 : i+3]
+	}
+	var sum [3]float64
+	add := func(j int) {
+		mask.Pix[mask.PixOffset(r.Min.X+j%w, r.Min.Y+j/w)] = 255
+		for k, v := range px(j) {
+			sum[k] += float64(v)
+		}
+	}
+	j0 := (seed.Y-r.Min.Y)*w + seed.X - r.Min.X
+	seen := make([]bool, w*h)
+	seen[j0] = true
+	add(j0)
+	queue, n := []int{j0}, 1
+	for len(queue) > 0 && n < maxArea {
+		i := queue[0]
+		queue = queue[1:]
+		x, y := i%w, i/w
+		for _, d := range conn4 {
+			nx, ny := x+d.X, y+d.Y
+			if nx < 0 || ny < 0 || nx >= w || ny >= h {
+				continue
+			}
+			j := ny*w + nx
+			if seen[j] {
+				continue
+			}
+			seen[j] = true
+			var dd float64
+			for k, v := range px(j) {
+				e := float64(v) - sum[k]/float64(n)
+				dd += e * e
+			}
+			if dd > tolerance*tolerance {
+				continue
+			}
+			add(j)
+			queue = append(queue, j)
+			if n++; n == maxArea {
+				break
+			}
+		}
+	}
+	return mask
+}
+
+// densest returns the nonzero pixel of mask with the most nonzero
+// pixels in the window×window square centered on it, the first in
+// row-major order on ties. It returns ok == false if mask is empty.
+func densest(mask *image.Alpha, window int) (p image.Point, ok bool) {
+	r := mask.Bounds()
+	w, h := r.Dx(), r.Dy()
+	at := pixAt(mask)
+	sum := integral(mask)
+	lo, hi := window/2, window-window/2
+	best := 0
+	for y := 0; y < h; y++ {
+		y0, y1 := clampInt(y-lo, 0, h), clampInt(y+hi, 0, h)
+		for x := 0; x < w; x++ {
+			if mask.Pix[at(y*w+x)] == 0 {
+				continue
+			}
+			x0, x1 := clampInt(x-lo, 0, w), clampInt(x+hi, 0, w)
+			if s := boxSum(sum, w, x0, y0, x1, y1); s > best {
+				best, p, ok = s, image.Pt(r.Min.X+x, r.Min.Y+y), true
+			}
+		}
+	}
+	return p, ok
+}
+
+// SkinMaskHysteresis detects skin with two sets of options, like the
+// dual thresholds of Canny's edge detector: pixels found with strict
+// seed regions, and pixels found only with loose join the mask where
+// they are 8-connected to a strict pixel. Solid faces are kept whole
+// while isolated background pixels that only loosely match are
+// dropped. Pixels found with strict are always kept.
+func SkinMaskHysteresis(src image.Image, strict, loose Options) (*image.Alpha, float64) {
+	strict.AsConstraint, loose.AsConstraint = false, false
+	s, _ := skinMaskColor(src, nil, &strict)
+	l, _ := skinMaskColor(src, nil, &loose)
+	hi, lo := s.(*image.Alpha), l.(*image.Alpha)
+	for i, v := range hi.Pix {
+		if v != 0 {
+			lo.Pix[i] = 255
+		}
+	}
+	labels, cs := label(lo, Conn8)
+	keep := make([]bool, len(cs)+1)
+	at := pixAt(hi)
+	for j, id := range labels {
+		if id != 0 && hi.Pix[at(j)] != 0 {
+			keep[id] = true
+		}
+	}
+	mask := image.NewAlpha(lo.Rect)
+	m := 0
+	for j, id := range labels {
+		if keep[id] {
+			mask.Pix[j] = 255
+			m++
+		}
+	}
+	r := mask.Bounds()
+	return mask, coverage(m, r.Dx()*r.Dy())
+}
@@ -0,0 +1,244 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestSkinMaskHysteresis(t *testing.T) {
+	looseOnly := color.RGBA{70, 58, 50, 255}
+	src := image.NewRGBA(image.Rect(0, 0, 100, 80))
+	paint(src, src.Rect, testBg)
+	paint(src, image.Rect(10, 10, 40, 50), testSkin)
+	rim := image.Rect(40, 10, 44, 50) // shadowed edge of the face
+	paint(src, rim, looseOnly)
+	blob := image.Rect(70, 30, 80, 40) // isolated, only loosely skin
+	paint(src, blob, looseOnly)
+
+	strict := Options{}
+	loose := Options{Thresholds: Thresholds{MinR: 50, MinRGDelta: 8, MaxRGDelta: 120, MaxRGRatio: 3}}
+	if l, _ := SkinMaskWith(src, nil, loose); !isSet(l, 75, 35) {
+		t.Fatal("blob is not skin in the loose options")
+	}
+	mask, _ := SkinMaskHysteresis(src, strict, loose)
+	for _, p := range []image.Point{{20, 20}, {41, 30}, {43, 49}} {
+		if !isSet(mask, p.X, p.Y) {
+			t.Errorf("face pixel %v dropped", p)
+		}
+	}
+	for y := blob.Min.Y; y < blob.Max.Y; y++ {
+		for x := blob.Min.X; x < blob.Max.X; x++ {
+			if isSet(mask, x, y) {
+				t.Fatalf("isolated loose blob kept at (%d, %d)", x, y)
+			}
+		}
+	}
+}
+
+// diagonalBridge returns a mask of two 3×3 blocks that touch only at
+// a corner, on a sub-image with a non-zero origin.
+func diagonalBridge() *image.Alpha {
+	m := image.NewAlpha(image.Rect(-2, -2, 12, 12)).SubImage(image.Rect(0, 0, 10, 10)).(*image.Alpha)
+	paint(m, image.Rect(1, 1, 4, 4), color.Alpha{255})
+	paint(m, image.Rect(4, 4, 7, 7), color.Alpha{255})
+	return m
+}
+
+func TestConnectivityDiagonalBridge(t *testing.T) {
+	for _, tc := range []struct {
+		conn    Connectivity
+		regions int
+		flood   int
+	}{
+		{Conn4, 2, 9},
+		{Conn8, 1, 18},
+	} {
+		m := diagonalBridge()
+		if n := RegionCount(m, tc.conn); n != tc.regions {
+			t.Errorf("conn %v: RegionCount = %d, want %d", tc.conn, n, tc.regions)
+		}
+		if cs := Components(m, tc.conn); len(cs) != tc.regions || cs[0].Area != 18/tc.regions {
+			t.Errorf("conn %v: Components = %+v, want %d of area %d", tc.conn, cs, tc.regions, 18/tc.regions)
+		}
+		if n := FloodFill(m, image.Pt(2, 2), 100, tc.conn); n != tc.flood {
+			t.Errorf("conn %v: FloodFill changed %d pixels, want %d", tc.conn, n, tc.flood)
+		}
+	}
+}
+
+func TestFillHolesDiagonal(t *testing.T) {
+	// A diamond: the four neighbors of (5, 5) set, its corners not.
+	// As a Conn8 region it encloses (5, 5); as Conn4 regions, the
+	// center escapes through the corners.
+	for _, tc := range []struct {
+		conn   Connectivity
+		filled int
+	}{
+		{Conn8, 1},
+		{Conn4, 0},
+	} {
+		m := image.NewAlpha(image.Rect(0, 0, 11, 11))
+		for _, p := range []image.Point{{4, 5}, {6, 5}, {5, 4}, {5, 6}} {
+			m.SetAlpha(p.X, p.Y, color.Alpha{255})
+		}
+		if n := FillHoles(m, tc.conn); n != tc.filled {
+			t.Errorf("conn %v: FillHoles filled %d, want %d", tc.conn, n, tc.filled)
+		}
+	}
+}
+
+func TestLooseCoverage(t *testing.T) {
+	r := image.Rect(-10, -10, 90, 90)
+	diagonal := func(m *image.Alpha) {
+		for i := 0; i < 30; i++ {
+			m.SetAlpha(i, i, color.Alpha{255})
+		}
+	}
+	for _, tc := range []struct {
+		name string
+		draw func(m *image.Alpha)
+		want float64
+	}{
+		{"empty", func(*image.Alpha) {}, 0},
+		{"diagonal", diagonal, 1.0 / 30},
+		{"square", func(m *image.Alpha) { paint(m, image.Rect(40, 40, 60, 70), color.Alpha{255}) }, 1},
+		{"ring", func(m *image.Alpha) {
+			paint(m, image.Rect(0, 0, 20, 20), color.Alpha{255})
+			paint(m, image.Rect(5, 5, 15, 15), color.Alpha{})
+		}, 300.0 / 400},
+		// Only the largest component counts: the square of 100
+		// pixels, not the diagonal of 30.
+		{"largest", func(m *image.Alpha) {
+			diagonal(m)
+			paint(m, image.Rect(60, 0, 70, 10), color.Alpha{255})
+		}, 1},
+	} {
+		m := image.NewAlpha(r)
+		tc.draw(m)
+		if got := LooseCoverage(m); got != tc.want {
+			t.Errorf("%s: LooseCoverage = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestFramingRatio(t *testing.T) {
+	r := image.Rect(-20, 10, 80, 110)
+	for _, tc := range []struct {
+		name   string
+		pixels []image.Rectangle
+		want   float64
+	}{
+		{"empty", nil, 0},
+		{"speck", []image.Rectangle{image.Rect(30, 40, 31, 41)}, 1.0 / 10000},
+		{"block", []image.Rectangle{image.Rect(0, 50, 10, 70)}, 200.0 / 10000},
+		// Two specks in opposite corners frame the whole mask.
+		{"spread", []image.Rectangle{image.Rect(-20, 10, -19, 11), image.Rect(79, 109, 80, 110)}, 1},
+	} {
+		m := image.NewAlpha(r)
+		for _, p := range tc.pixels {
+			paint(m, p, color.Alpha{1})
+		}
+		if got := FramingRatio(m); got != tc.want {
+			t.Errorf("%s: FramingRatio = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestCoverageWeighted(t *testing.T) {
+	r := image.Rect(5, -5, 25, 15)
+	mask := image.NewAlpha(r)
+	paint(mask, image.Rect(5, -5, 15, 15), color.Alpha{255}) // left half
+	weight := image.NewGray(r)
+	paint(weight, image.Rect(5, -5, 15, 15), color.Gray{30})
+	paint(weight, image.Rect(15, -5, 25, 15), color.Gray{10})
+	if got := CoverageWeighted(mask, weight); got != 0.75 {
+		t.Errorf("CoverageWeighted = %v, want 0.75", got)
+	}
+	if got := CoverageWeighted(mask, image.NewGray(r)); got != 0 {
+		t.Errorf("zero weights: CoverageWeighted = %v, want 0", got)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for differing bounds")
+		}
+	}()
+	CoverageWeighted(mask, image.NewGray(r.Add(image.Pt(1, 0))))
+}
+
+func TestGrowFromSeed(t *testing.T) {
+	r := image.Rect(-10, -10, 90, 70)
+	src := image.NewRGBA(r)
+	paint(src, r, testBg)
+	small, large := image.Rect(0, 0, 10, 10), image.Rect(40, 20, 70, 50)
+	paint(src, small, testSkin)
+	paint(src, large, testSkin)
+	// A soft gradient across the large region stays within tolerance.
+	for x := large.Min.X; x < large.Max.X; x++ {
+		c := testSkin
+		c.R += uint8((x - large.Min.X) / 6)
+		paint(src, image.Rect(x, large.Min.Y, x+1, large.Max.Y), c)
+	}
+	in := func(b image.Rectangle) func(x, y int) bool {
+		return func(x, y int) bool { return image.Pt(x, y).In(b) }
+	}
+	for _, tc := range []struct {
+		name string
+		seed image.Point
+		want image.Rectangle
+	}{
+		{"seeded", image.Pt(5, 5), small},
+		{"seeded in gradient", image.Pt(69, 49), large},
+		// Outside src, growth starts from the densest skin, which
+		// is in the large region.
+		{"densest", image.Pt(-100, -100), large},
+	} {
+		mask := GrowFromSeed(src, tc.seed, 10)
+		if mask.Rect != r {
+			t.Fatalf("%s: bounds %v, want %v", tc.name, mask.Rect, r)
+		}
+		if inside, outside := countSet(mask, in(tc.want)); inside != tc.want.Dx()*tc.want.Dy() || outside != 0 {
+			t.Errorf("%s: %d pixels of %v and %d others, want all and none", tc.name, inside, tc.want, outside)
+		}
+	}
+
+	bg := image.NewRGBA(r)
+	paint(bg, r, testBg)
+	if inside, _ := countSet(GrowFromSeed(bg, image.Pt(200, 0), 10), in(r)); inside != 0 {
+		t.Errorf("no skin: %d pixels grown, want 0", inside)
+	}
+}
+
+func TestFitEllipse(t *testing.T) {
+	if _, _, _, _, ok := FitEllipse(image.NewAlpha(image.Rect(0, 0, 10, 10))); ok {
+		t.Error("empty mask: ok == true")
+	}
+
+	// A solid ellipse with semi-axes 30 and 12, its major axis 30°
+	// clockwise from the x axis, and a smaller blob that is ignored.
+	r := image.Rect(-50, -40, 50, 60)
+	const a, b, theta = 30.0, 12.0, math.Pi / 6
+	cx, cy := -5.0, 10.0
+	sin, cos := math.Sincos(theta)
+	m := image.NewAlpha(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			u, v := dx*cos+dy*sin, -dx*sin+dy*cos
+			if u*u/(a*a)+v*v/(b*b) <= 1 {
+				m.SetAlpha(x, y, color.Alpha{255})
+			}
+		}
+	}
+	paint(m, image.Rect(30, 40, 40, 50), color.Alpha{255})
+
+	center, rx, ry, got, ok := FitEllipse(m)
+	if !ok || center != image.Pt(-5, 10) {
+		t.Fatalf("center %v, ok %v; want (-5,10), true", center, ok)
+	}
+	if math.Abs(rx-a) > 0.5 || math.Abs(ry-b) > 0.5 || math.Abs(got-theta) > 0.01 {
+		t.Errorf("rx, ry, theta = %.2f, %.2f, %.3f; want %v, %v, %.3f", rx, ry, got, a, b, theta)
+	}
+}
@@ -0,0 +1,205 @@
+package face
+
+import (
+	"image"
+	"image/draw"
+	"math"
+)
+
+// StretchSkin stretches the contrast of the pixels of src under the
+// nonzero pixels of mask, in place. The luma range (r+g+b)/3 of those
+// pixels is mapped linearly onto [0, 255] by applying the same map
+// to each channel. Pixels outside the mask are not modified.
+func StretchSkin(src *image.RGBA, mask *image.Alpha) {
+	r := src.Bounds().Intersect(mask.Bounds())
+	lo, hi := 255, 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp, mp := src.PixOffset(r.Min.X, y), mask.PixOffset(r.Min.X, y)
+		for x := 0; x < r.Dx(); x, sp, mp = x+1, sp+4, mp+1 {
+			if mask.Pix[mp] == 0 {
+				continue
+			}
+			p := src.Pix[sp : sp+3]
+			l := (int(p[0]) + int(p[1]) + int(p[2])) / 3
+			if l < lo {
+				lo = l
+			}
+			if l > hi {
+				hi = l
+			}
+		}
+	}
+	if hi <= lo {
+		return
+	}
+	var lut [256]uint8
+	for i := range lut {
+		v := (i - lo) * 255 / (hi - lo)
+		if v < 0 {
+			v = 0
+		} else if v > 255 {
+			v = 255
+		}
+		lut[i] = uint8(v)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp, mp := src.PixOffset(r.Min.X, y), mask.PixOffset(r.Min.X, y)
+		for x := 0; x < r.Dx(); x, sp, mp = x+1, sp+4, mp+1 {
+			if mask.Pix[mp] == 0 {
+				continue
+			}
+			p := src.Pix[sp : sp+4]
+			p[0], p[1], p[2] = clampTo(lut[p[0]], p[3]), clampTo(lut[p[1]], p[3]), clampTo(lut[p[2]], p[3])
+		}
+	}
+}
+
+// Cutout returns a copy of src in which skin pixels keep their color
+// and all others are fully transparent, ready to composite with
+// draw.Over, and the skin coverage as returned by SkinMask.
+func Cutout(src image.Image) (*image.RGBA, float64) {
+	mask, cover := skinMaskColor(src, nil, &Options{})
+	a := mask.(*image.Alpha)
+	r := src.Bounds()
+	dst := image.NewRGBA(r)
+	draw.Draw(dst, r, src, r.Min, draw.Src)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		dp, mp := dst.PixOffset(r.Min.X, y), a.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x, dp, mp = x+1, dp+4, mp+1 {
+			if a.Pix[mp] == 0 {
+				p := dst.Pix[dp : dp+4 : dp+4]
+				p[0], p[1], p[2], p[3] = 0, 0, 0, 0
+			}
+		}
+	}
+	return dst, cover
+}
+
+// clampTo limits v to a, keeping premultiplied colors valid.
+func clampTo(v, a uint8) uint8 {
+	if v > a {
+		return a
+	}
+	return v
+}
+
+// UprightCrop rotates src so the ellipse fitted to the largest
+// region of mask stands upright and crops the result to the
+// ellipse's bounds. The rotation undoes the tilt of the major axis
+// from vertical, in [-π/2, π/2), and the source is sampled
+// bilinearly. The result is origin-based; samples falling outside
+// src are transparent. If mask has no region, src is returned as an
+// *image.RGBA, uncropped.
+func UprightCrop(src image.Image, mask *image.Alpha) *image.RGBA {
+	img := toRGBA(src)
+	m, ok := largestMoments(mask)
+	if !ok {
+		return img
+	}
+	l1, l2, theta := m.axes()
+	rx, ry := 2*math.Sqrt(l1), 2*math.Sqrt(l2)
+	tilt := theta - math.Pi/2
+	for tilt < -math.Pi/2 {
+		tilt += math.Pi
+	}
+	for tilt >= math.Pi/2 {
+		tilt -= math.Pi
+	}
+	w, h := int(math.Ceil(2*ry)), int(math.Ceil(2*rx))
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	sin, cos := math.Sincos(tilt)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			u, v := float64(x)+0.5-float64(w)/2, float64(y)+0.5-float64(h)/2
+			c, _ := bilinear(img, m.cx+u*cos-v*sin, m.cy+u*sin+v*cos)
+			dst.SetRGBA(x, y, c)
+		}
+	}
+	return dst
+}
+
+// SkinCoverageAffine returns the coverage of skin in src transformed
+// by the affine map m and seen through out, without materializing
+// the transformed image. The map takes a point (x, y) of src to
+//
+//	(m[0]x + m[1]y + m[2], m[3]x + m[4]y + m[5])
+//
+// Each pixel center of out is mapped back into src and sampled
+// bilinearly; samples outside src are not skin. A singular map
+// yields 0. The standard image types of rowRGB are sampled in place;
+// other sources are read through At.
+func SkinCoverageAffine(src image.Image, m [6]float64, out image.Rectangle) float64 {
+	det := m[0]*m[4] - m[1]*m[3]
+	if det == 0 || out.Empty() {
+		return 0
+	}
+	// The inverse map, from out back to src.
+	a, b, d, e := m[4]/det, -m[1]/det, -m[3]/det, m[0]/det
+	c, f := -(a*m[2] + b*m[5]), -(d*m[2] + e*m[5])
+	n := 0
+	for y := out.Min.Y; y < out.Max.Y; y++ {
+		for x := out.Min.X; x < out.Max.X; x++ {
+			u, v := float64(x)+0.5, float64(y)+0.5
+			if p, ok := bilinearRGB(src, a*u+b*v+c, d*u+e*v+f); ok && IsSkin(p[0], p[1], p[2]) {
+				n++
+			}
+		}
+	}
+	return coverage(n, out.Dx()*out.Dy())
+}
+
+// Subregions finds the lips and eyes within the nonzero pixels of
+// faceMask, for makeup and retouching tools. It is a heuristic on
+// color, not a landmark detector: against the mean luma (r+g+b)/3 and
+// mean r−g of the face, eyes are the pixels darker than half the
+// mean luma, and lips those, not eyes, whose r−g exceeds the mean by
+// 24 or more. Pixels outside faceMask are never set. The masks have
+// the bounds of faceMask and are empty when nothing matches.
+//
+// SkinMask leaves the eyes, too dark to pass as skin, as holes in the
+// face, so Subregions reads faceMask with its holes filled, on a
+// copy; a mask from SkinMask can be passed as is.
+func Subregions(src image.Image, faceMask *image.Alpha) (lips, eyes *image.Alpha) {
+	const lipDelta = 24
+	r := faceMask.Bounds()
+	lips, eyes = image.NewAlpha(r), image.NewAlpha(r)
+	face := image.NewAlpha(r)
+	draw.Draw(face, r, faceMask, r.Min, draw.Src)
+	FillHoles(face, Conn8)
+	faceMask = face
+	r = r.Intersect(src.Bounds())
+	each := func(f func(x, y, l, d int)) {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			mp := faceMask.PixOffset(r.Min.X, y)
+			for x := r.Min.X; x < r.Max.X; x, mp = x+1, mp+1 {
+				if faceMask.Pix[mp] == 0 {
+					continue
+				}
+				cr, cg, cb, _ := src.At(x, y).RGBA()
+				pr, pg, pb := to8(cr, cg, cb)
+				f(x, y, (int(pr)+int(pg)+int(pb))/3, int(pr)-int(pg))
+			}
+		}
+	}
+	var sl, sd, n int
+	each(func(_, _, l, d int) { sl, sd, n = sl+l, sd+d, n+1 })
+	if n == 0 {
+		return lips, eyes
+	}
+	ml, md := sl/n, sd/n
+	each(func(x, y, l, d int) {
+		switch {
+		case 2*l < ml:
+			eyes.Pix[eyes.PixOffset(x, y)] = 255
+		case d >= md+lipDelta:
+			lips.Pix[lips.PixOffset(x, y)] = 255
+		}
+	})
+	return lips, eyes
+}
@@ -0,0 +1,200 @@
+package face
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestStretchSkinBackground(t *testing.T) {
+	src := randRGBA(image.Rect(-4, 0, 60, 40), 12)
+	m, _ := SkinMask(src, nil)
+	mask := m.(*image.Alpha)
+	before := append([]uint8(nil), src.Pix...)
+	StretchSkin(src, mask)
+
+	changed := false
+	for i, v := range mask.Pix {
+		p, q := src.Pix[4*i:4*i+4], before[4*i:4*i+4]
+		switch {
+		case v == 0 && !bytes.Equal(p, q):
+			t.Fatalf("background pixel %d changed from %v to %v", i, q, p)
+		case v != 0 && !bytes.Equal(p, q):
+			changed = true
+		}
+	}
+	if !changed {
+		t.Error("no skin pixel was stretched")
+	}
+}
+
+func TestSkinCoverageAffine(t *testing.T) {
+	r := image.Rect(-5, 3, 75, 63)
+	rgba := randRGBA(r, 13)
+	srcs := []image.Image{
+		rgba,
+		atNRGBA(rgba),
+		randYCbCr(image.Rect(0, 0, 80, 60), image.YCbCrSubsampleRatio420, 13),
+		fuzzSource(3, r, 13),
+		generic{rgba},
+	}
+	// Rotation by 30° and scaling by 1.25, shifted into out, which
+	// also takes in points outside src.
+	sin, cos := 1.25*math.Sin(math.Pi/6), 1.25*math.Cos(math.Pi/6)
+	m := [6]float64{cos, -sin, 40, sin, cos, -10}
+	out := image.Rect(0, 0, 100, 100)
+	for _, src := range srcs {
+		// The map as computed before, on a converted copy.
+		img := atRGBA(src)
+		det := m[0]*m[4] - m[1]*m[3]
+		a, b, d, e := m[4]/det, -m[1]/det, -m[3]/det, m[0]/det
+		c, f := -(a*m[2] + b*m[5]), -(d*m[2] + e*m[5])
+		n := 0
+		for y := out.Min.Y; y < out.Max.Y; y++ {
+			for x := out.Min.X; x < out.Max.X; x++ {
+				u, v := float64(x)+0.5, float64(y)+0.5
+				if p, ok := bilinear(img, a*u+b*v+c, d*u+e*v+f); ok && IsSkin(p.R, p.G, p.B) {
+					n++
+				}
+			}
+		}
+		want := coverage(n, out.Dx()*out.Dy())
+		var got float64
+		allocs := testing.AllocsPerRun(2, func() { got = SkinCoverageAffine(src, m, out) })
+		if _, at := src.(generic); allocs != 0 && !at {
+			t.Errorf("%T: %v allocations per run", src, allocs)
+		}
+		if got != want || got == 0 {
+			t.Errorf("%T: coverage %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestSubregions(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 100, 120))
+	paint(src, src.Rect, testBg)
+	paint(src, image.Rect(20, 10, 80, 110), testSkin)
+	eyes := []image.Rectangle{image.Rect(32, 40, 42, 46), image.Rect(58, 40, 68, 46)}
+	for _, r := range eyes {
+		paint(src, r, color.RGBA{45, 30, 30, 255})
+	}
+	lips := image.Rect(38, 80, 62, 88)
+	paint(src, lips, color.RGBA{200, 60, 70, 255})
+
+	// Both are holes in the mask SkinMask returns.
+	m, _ := SkinMask(src, nil)
+	face := m.(*image.Alpha)
+	for _, r := range append(eyes, lips) {
+		if in, _ := countSet(face.SubImage(r), func(x, y int) bool { return true }); in != 0 {
+			t.Fatalf("SkinMask sets %d pixels of %v", in, r)
+		}
+	}
+
+	gotLips, gotEyes := Subregions(src, face)
+	in := func(rs ...image.Rectangle) func(x, y int) bool {
+		return func(x, y int) bool {
+			for _, r := range rs {
+				if image.Pt(x, y).In(r) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	if inside, outside := countSet(gotEyes, in(eyes...)); inside != 2*60 || outside != 0 {
+		t.Errorf("eyes: %d of %d pixels, %d others", inside, 2*60, outside)
+	}
+	if inside, outside := countSet(gotLips, in(lips)); inside != 24*8 || outside != 0 {
+		t.Errorf("lips: %d of %d pixels, %d others", inside, 24*8, outside)
+	}
+	if countHoles(face) == 0 {
+		t.Error("Subregions filled the caller's mask")
+	}
+}
+
+// countHoles returns the number of pixels FillHoles would set in a
+// copy of mask.
+func countHoles(mask *image.Alpha) int {
+	c := image.NewAlpha(mask.Rect)
+	copy(c.Pix, mask.Pix)
+	return FillHoles(c, Conn8)
+}
+
+func TestUprightCrop(t *testing.T) {
+	// An ellipse with semi-axes 40 and 15, its major axis tilted 30°
+	// from vertical, in a source and mask that are not origin-based.
+	r := image.Rect(10, 20, 150, 160)
+	const a, b = 40.0, 15.0
+	cx, cy := 80.0, 90.0
+	sin, cos := math.Sincos(math.Pi / 6)
+	src := image.NewRGBA(r)
+	mask := image.NewAlpha(r)
+	paint(src, r, testBg)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dx, dy := float64(x)+0.5-cx, float64(y)+0.5-cy
+			// Coordinates across and along the major axis.
+			u, v := dx*cos+dy*sin, -dx*sin+dy*cos
+			if u*u/(b*b)+v*v/(a*a) <= 1 {
+				src.SetRGBA(x, y, testSkin)
+				mask.SetAlpha(x, y, color.Alpha{255})
+			}
+		}
+	}
+
+	dst := UprightCrop(src, mask)
+	w, h := dst.Rect.Dx(), dst.Rect.Dy()
+	if dst.Rect.Min != (image.Point{}) || math.Abs(float64(w)-2*b) > 2 || math.Abs(float64(h)-2*a) > 2 {
+		t.Fatalf("bounds %v, want about 30×80 at the origin", dst.Rect)
+	}
+	in, out := 0, 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			u, v := (float64(x)+0.5-float64(w)/2)/b, (float64(y)+0.5-float64(h)/2)/a
+			switch d, c := u*u+v*v, dst.RGBAAt(x, y); {
+			case d < 0.7 && c != testSkin:
+				in++
+			case d > 1.4 && c != testBg:
+				out++
+			}
+		}
+	}
+	if in != 0 || out != 0 {
+		t.Errorf("not upright: %d pixels inside the ellipse and %d outside have the wrong color", in, out)
+	}
+
+	if got := UprightCrop(src, image.NewAlpha(r)); got != src {
+		t.Error("an empty mask does not return src")
+	}
+}
+
+func TestCutout(t *testing.T) {
+	for _, r := range []image.Rectangle{image.Rect(0, 0, 64, 48), image.Rect(-7, 5, 57, 53)} {
+		src := randRGBA(r, 14)
+		m, want := SkinMask(src, nil)
+		mask := m.(*image.Alpha)
+		dst, cover := Cutout(src)
+		if dst.Rect != r || cover != want {
+			t.Fatalf("%v: bounds %v, coverage %v; want %v, %v", r, dst.Rect, cover, r, want)
+		}
+		skin := 0
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				got, c := dst.RGBAAt(x, y), src.RGBAAt(x, y)
+				if mask.AlphaAt(x, y).A == 0 {
+					c = color.RGBA{}
+				} else {
+					skin++
+				}
+				if got != c {
+					t.Fatalf("%v: pixel (%d, %d) is %v, want %v", r, x, y, got, c)
+				}
+			}
+		}
+		if skin == 0 || skin == r.Dx()*r.Dy() {
+			t.Errorf("%v: %d skin pixels, want some but not all", r, skin)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package face
+
+import (
+	"image"
+	"math"
+)
+
+// ScoreWeights weigh the cues blended by FaceScoreWith. Weights are
+// relative; they need not sum to one. The zero value weighs every
+// cue equally.
+type ScoreWeights struct {
+	Coverage    float64
+	Compactness float64
+	Holes       float64
+	Circularity float64
+}
+
+// FaceScore is FaceScoreWith using the default options.
+func FaceScore(src image.Image) float64 {
+	return FaceScoreWith(src, Options{})
+}
+
+// FaceScoreWith rates how likely src is to contain a face, in
+// [0, 1]. It is a hand-tuned heuristic, not a trained model. The
+// skin mask is computed with opt and its largest region is rated by
+// four cues, each in [0, 1]:
+//
+//	Coverage     region area over image area, saturating at 5%,
+//	             each pixel weighed by its confidence as rated by
+//	             SkinProbabilityRGBA, so a region deep inside the
+//	             skin band outscores one along its edge
+//	Compactness  1 − |f/(π/4) − 1| where f is the fraction of its
+//	             bounding box the region fills, so an ellipse
+//	             scores 1 and squares and ragged blobs score less
+//	Holes        1 for 1 to 6 holes (eyes, mouth), 0.5 for none,
+//	             6/n for n > 6
+//	Circularity  4πA/P² normalized so a digital disk scores 1, where
+//	             P counts the region's boundary pixels; a square
+//	             scores about 0.64
+//
+// The score is the weighted mean of the cues using opt.Weights. It
+// is 0 for empty and achromatic images, and when no skin is found.
+func FaceScoreWith(src image.Image, opt Options) float64 {
+	r := src.Bounds()
+	if r.Empty() || SelectMode(src) == ModeNone {
+		return 0
+	}
+	mask, _ := skinMaskColor(src, nil, &opt)
+	f, ok := regionFeatures(src, mask.(*image.Alpha), r.Dx()*r.Dy())
+	if !ok {
+		return 0
+	}
+	return opt.Weights.blend(f)
+}
+
+// FaceBoundaryScore rates how likely the largest skin region of src
+// is a face rather than a skin-colored surface, in [0, 1]. A face has
+// a solid interior and strong edges where it meets hair and
+// background; a blanket or wall is solid but fades into its
+// surroundings. The score is the geometric mean of two cues, so
+// either alone scores low:
+//
+//	Interior  the fraction of its bounding box the region fills,
+//	          over π/4 so that an ellipse scores 1, clamped
+//	Edges     the fraction of the region's boundary pixels where
+//	          the Sobel gradient of luma, |gx|+|gy|, is at least 128
+//
+// It is 0 when no skin is found.
+func FaceBoundaryScore(src image.Image) float64 {
+	const (
+		minEdge = 128
+	)
+	mask, _ := skinMaskColor(src, nil, &Options{})
+	labels, cs := label(mask.(*image.Alpha), Conn8)
+	id := largestID(cs)
+	if id == 0 {
+		return 0
+	}
+	c := cs[id-1]
+	r := src.Bounds()
+	w, h := r.Dx(), r.Dy()
+	b := c.Bounds.Sub(r.Min)
+	mag := sobel(toRGBA(src))
+	in := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && labels[y*w+x] == id
+	}
+	edge, p := 0, 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if !in(x, y) {
+				continue
+			}
+			for _, d := range conn4 {
+				if !in(x+d.X, y+d.Y) {
+					p++
+					if mag[y*w+x] >= minEdge {
+						edge++
+					}
+					break
+				}
+			}
+		}
+	}
+	interior := clamp01(float64(c.Area) / (math.Pi / 4 * float64(b.Dx()*b.Dy())))
+	return math.Sqrt(interior * float64(edge) / float64(p))
+}
+
+// features are the cues rated by FaceScoreWith, each in [0, 1].
+type features struct {
+	coverage, compactness, holes, circularity float64
+}
+
+func (w ScoreWeights) blend(f features) float64 {
+	if w == (ScoreWeights{}) {
+		w = ScoreWeights{1, 1, 1, 1}
+	}
+	sum := w.Coverage + w.Compactness + w.Holes + w.Circularity
+	if sum <= 0 {
+		return 0
+	}
+	return (w.Coverage*f.coverage + w.Compactness*f.compactness + w.Holes*f.holes + w.Circularity*f.circularity) / sum
+}
+
+// regionFeatures rates the largest component of mask, the skin mask
+// of src, an image of the given area.
+func regionFeatures(src image.Image, mask *image.Alpha, area int) (f features, ok bool) {
+	labels, cs := label(mask, Conn8)
+	id := largestID(cs)
+	if id == 0 {
+		return f, false
+	}
+	c := cs[id-1]
+	w := mask.Bounds().Dx()
+	b := c.Bounds.Sub(mask.Bounds().Min)
+
+	f.coverage = clamp01(weight(src, labels, w, id, b) / float64(area) / 0.05)
+	f.compactness = clamp01(1 - math.Abs(float64(c.Area)/(math.Pi/4*float64(b.Dx()*b.Dy()))-1))
+	switch n := holes(labels, w, id, b); {
+	case n == 0:
+		f.holes = 0.5
+	case n <= 6:
+		f.holes = 1
+	default:
+		f.holes = 6 / float64(n)
+	}
+	p := float64(c.Perimeter)
+	f.circularity = clamp01(32 * float64(c.Area) / (math.Pi * p * p))
+	return f, true
+}
+
+// weight sums the confidence, in [0.5, 1], of the pixels of src in
+// the region labeled id. The region lies within b, relative to the
+// origin of src, in the label grid of width w.
+func weight(src image.Image, labels []int32, w int, id int32, b image.Rectangle) float64 {
+	o := src.Bounds().Min
+	sum := 0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if labels[y*w+x] != id {
+				continue
+			}
+			r, g, _, _ := src.At(o.X+x, o.Y+y).RGBA()
+			sum += int(confidence(uint8(r>>8), uint8(g>>8)))
+		}
+	}
+	return float64(sum) / 255
+}
+
+// largestID returns the label of the largest of cs, or 0 if cs is
+// empty.
+func largestID(cs []Component) int32 {
+	id, best := int32(0), 0
+	for i, c := range cs {
+		if c.Area > best {
+			id, best = int32(i+1), c.Area
+		}
+	}
+	return id
+}
+
+// holes counts the regions of pixels not labeled id that the region
+// labeled id encloses. The region lies within b in the label grid of
+// width w. Holes are traced 4-connected, the complement of the
+// region's 8-connectivity.
+func holes(labels []int32, w int, id int32, b image.Rectangle) int {
+	// Work in b padded by one pixel so the outside is one region.
+	pw, ph := b.Dx()+2, b.Dy()+2
+	seen := make([]bool, pw*ph)
+	bg := func(j int) bool {
+		x, y := j%pw-1+b.Min.X, j/pw-1+b.Min.Y
+		if x < b.Min.X || y < b.Min.Y || x >= b.Max.X || y >= b.Max.Y {
+			return true
+		}
+		return labels[y*w+x] != id
+	}
+	in := func(j int) bool { return !seen[j] && bg(j) }
+	visit := func(j int) { seen[j] = true }
+	var stack []int
+	n := -1 // the outside
+	for j := range seen {
+		if in(j) {
+			stack = flood(pw, ph, Conn4, j, stack, in, visit)
+			n++
+		}
+	}
+	return n
+}
+
+func clamp01(v float64) float64 {
+	switch {
+	case v < 0:
+		return 0
+	case v > 1:
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,41 @@
+package face
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestFaceBoundaryScore(t *testing.T) {
+	// ellipse returns an image of an upright ellipse of skin on bg.
+	r := image.Rect(-30, 10, 90, 150)
+	ellipse := func(bg color.RGBA) *image.RGBA {
+		img := image.NewRGBA(r)
+		paint(img, r, bg)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				u, v := (float64(x)+0.5-30)/40, (float64(y)+0.5-80)/55
+				if u*u+v*v <= 1 {
+					img.SetRGBA(x, y, testSkin)
+				}
+			}
+		}
+		return img
+	}
+	// The luma of the gray matches that of the skin, leaving no edge.
+	gray := color.RGBA{145, 145, 145, 255}
+	if got := FaceBoundaryScore(ellipse(testBg)); got < 0.9 {
+		t.Errorf("face against a dark background scores %v, want at least 0.9", got)
+	}
+	if got := FaceBoundaryScore(generic{ellipse(testBg)}); got < 0.9 {
+		t.Errorf("face read through At scores %v, want at least 0.9", got)
+	}
+	if got := FaceBoundaryScore(ellipse(gray)); got > 0.2 {
+		t.Errorf("surface fading into its surroundings scores %v, want at most 0.2", got)
+	}
+	bg := image.NewRGBA(r)
+	paint(bg, r, testBg)
+	if got := FaceBoundaryScore(bg); got != 0 {
+		t.Errorf("no skin scores %v, want 0", got)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"time"
 )
 
 // SkinMask sets mask to covering non-facial colors in the RGB
@@ -13,42 +14,265 @@ import (
 // have a non-zero alpha.
 //
 // The mask dimensions correspond to the pixels processed by this
-// function in src. If src is an *image.RGBA and mask is nil or an
-// *image.Alpha, this function takes a fast-path if the bounds are
-// the same for src and mask (or mask is nil).
+// function in src. Bounds need not start at the origin: pixels are
+// matched by coordinate, so a subimage or an image with a negative
+// Min works as expected. If src is an *image.RGBA and mask is nil or an
+// *image.Alpha, this function takes a fast-path if the bounds of
+// mask lie within those of src (or mask is nil), so a region of
+// interest is processed by passing a mask of just that region.
 //
 // Note: This function currently assumes the input image is chromatic
 // using a grayscale image will yield poor results.
 func SkinMask(src image.Image, mask draw.Image) (mask0 draw.Image, cover float64) {
-	return skinMaskColor(src, mask)
+	return skinMaskColor(src, mask, &Options{})
+}
+
+// SkinMaskRGBA is SkinMask for the concrete types of its fast path,
+// for tight loops over frames already held as *image.RGBA. It writes
+// every pixel of mask, 255 for skin and 0 otherwise, and returns the
+// coverage. Only the pixels of src under mask are classified; the
+// bounds of mask must lie within those of src, otherwise it panics.
+func SkinMaskRGBA(src *image.RGBA, mask *image.Alpha) float64 {
+	if !mask.Rect.In(src.Rect) {
+		panic("face: SkinMaskRGBA: mask bounds outside src")
+	}
+	r := mask.Rect
+	if r.Empty() {
+		return 0
+	}
+	m := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp, mp := src.PixOffset(r.Min.X, y), mask.PixOffset(r.Min.X, y)
+		pix, dst := src.Pix[sp:sp+r.Dx()*4], mask.Pix[mp:mp+r.Dx()]
+		for i := range dst {
+			p := pix[i*4 : i*4+3 : i*4+3]
+			if IsSkin(p[0], p[1], p[2]) {
+				dst[i] = 255
+				m++
+			} else {
+				dst[i] = 0
+			}
+		}
+	}
+	return coverage(m, r.Dx()*r.Dy())
+}
+
+// SkinMaskRegionInto re-detects skin in the part of src within
+// region and writes it in place, 255 for skin and 0 otherwise, into
+// the same pixels of dst, a mask of the whole of src, so that an
+// edited region can be updated without reprocessing the rest. Pixels
+// of dst outside region are not modified.
+func SkinMaskRegionInto(src image.Image, dst *image.Alpha, region image.Rectangle) {
+	region = region.Intersect(dst.Rect)
+	if region.Empty() {
+		return
+	}
+	sub := dst.SubImage(region).(*image.Alpha)
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		mp := sub.PixOffset(region.Min.X, y)
+		row := sub.Pix[mp : mp+region.Dx()]
+		for i := range row {
+			row[i] = 0
+		}
+	}
+	if ss, ok := src.(subImager); ok {
+		src = ss.SubImage(region)
+	}
+	skinMaskColor(src, sub, &Options{})
+}
+
+// SkinMaskStream computes the mask of SkinMask one row at a time,
+// top to bottom, passing each to onRow with its y coordinate, so the
+// mask can be piped to an encoder without holding all of it. The row
+// holds the alpha of the pixels from src.Bounds().Min.X on; it is
+// reused and valid only during the call. Detection stops when onRow
+// returns false. It returns the coverage of the rows computed.
+func SkinMaskStream(src image.Image, onRow func(y int, row []uint8) bool) float64 {
+	r := src.Bounds()
+	row := image.NewAlpha(image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1))
+	ss, sub := src.(subImager)
+	m, n := 0, 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for i := range row.Pix {
+			row.Pix[i] = 0
+		}
+		row.Rect = image.Rect(r.Min.X, y, r.Max.X, y+1)
+		s := src
+		if sub {
+			s = ss.SubImage(row.Rect)
+		}
+		_, rm, rn := skinMask(s, row, &Options{})
+		m, n = m+rm, n+rn
+		if !onRow(y, row.Pix) {
+			break
+		}
+	}
+	return coverage(m, n)
+}
+
+// SkinCoverage returns the coverage SkinMask would report for src
+// without writing, or allocating, a mask. The standard image types
+// of rowRGB are read without allocating at all; other sources are
+// read through At, which may allocate per pixel.
+func SkinCoverage(src image.Image) float64 {
+	r := src.Bounds()
+	return coverage(skinCount(src, r, skinRGB), r.Dx()*r.Dy())
+}
+
+// SkinCounts returns the number of pixels of src within r that
+// SkinMask finds to be skin, and the number classified, for folding
+// the results of several regions into a CoverageAccumulator.
+func SkinCounts(src image.Image, r image.Rectangle) (skin, total int) {
+	r = r.Intersect(src.Bounds())
+	return skinCount(src, r, skinRGB), r.Dx() * r.Dy()
+}
+
+// CoverageAccumulator folds the pixel counts of several detections,
+// such as the tiles of a scan, into one coverage. It sums integer
+// counts, so the result is exact whatever the order or number of
+// parts. The zero value is empty. It is not safe for concurrent use;
+// parallel workers should each keep one and Merge them.
+type CoverageAccumulator struct {
+	skin, total int
+}
+
+// Add adds skinPixels skin pixels out of totalPixels classified.
+func (a *CoverageAccumulator) Add(skinPixels, totalPixels int) {
+	a.skin += skinPixels
+	a.total += totalPixels
+}
+
+// Merge adds the counts of b.
+func (a *CoverageAccumulator) Merge(b CoverageAccumulator) {
+	a.Add(b.skin, b.total)
+}
+
+// Fraction returns the coverage of the counts added, or 0 if there
+// are none.
+func (a *CoverageAccumulator) Fraction() float64 {
+	return coverage(a.skin, a.total)
+}
+
+// skinCount counts the pixels of src within r that skin accepts.
+func skinCount(src image.Image, r image.Rectangle, skin func(r, g, b uint8) bool) (m int) {
+	r = r.Intersect(src.Bounds())
+	switch src := src.(type) {
+	case *image.RGBA:
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			sp := src.PixOffset(r.Min.X, y)
+			for pix, ep := src.Pix, sp+r.Dx()*4; sp != ep; sp += 4 {
+				if skin(pix[sp], pix[sp+1], pix[sp+2]) {
+					m++
+				}
+			}
+		}
+	case *image.CMYK:
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			sp := src.PixOffset(r.Min.X, y)
+			for pix, ep := src.Pix, sp+r.Dx()*4; sp != ep; sp += 4 {
+				if skin(color.CMYKToRGB(pix[sp], pix[sp+1], pix[sp+2], pix[sp+3])) {
+					m++
+				}
+			}
+		}
+	default:
+		// Rows are read in chunks into a buffer on the stack, so
+		// that counting allocates nothing.
+		const chunk = 256
+		var rgb [3 * chunk]uint8
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x += chunk {
+				buf := rowRGB(src, y, x, clampInt(x+chunk, x, r.Max.X), rgb[:0])
+				for i := 0; i < len(buf); i += 3 {
+					if skin(buf[i], buf[i+1], buf[i+2]) {
+						m++
+					}
+				}
+			}
+		}
+	}
+	return m
 }
 
 // Content rates the level of posterization in the provided image in
 // r in the range [0, 256). The range [0, 64] generally indicates that
 // src is highly posterized.
 //
-// If src.Bounds == r, and src is an *image.RGBA, a fast-
-// path is taken.
+// If r lies within src.Bounds, and src is an *image.RGBA, a fast-
+// path is taken. Other sources are read a row at a time, directly
+// for the standard types of rowRGB.
 func Content(src image.Image, r image.Rectangle) uint8 {
 	const (
 		threshold = 64
 	)
-	if src.Bounds() == r {
+	if !r.Empty() && r.In(src.Bounds()) {
 		src, ok := src.(*image.RGBA)
 		if ok {
-			return contentRGBA(src)
+			return contentRGBA(src, r)
 		}
 	}
-	Y := 0
-	C := [256 * 3]byte{}
-	for y := r.Min.Y; y <= r.Max.Y; y++ {
-		for x := r.Min.X; x <= r.Max.X; x++ {
-			r, g, b, _ := src.At(x, y).RGBA()
-			r >>= 8
-			g >>= 8
-			b >>= 8
-			Y = int(r+g+b) / 3
-			C[byte(Y)]++
+	C := [256]int{}
+	var buf []uint8
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		buf = rowRGB(src, y, r.Min.X, r.Max.X, buf)
+		for i := 0; i < len(buf); i += 3 {
+			C[(int(buf[i])+int(buf[i+1])+int(buf[i+2]))/3]++
+		}
+	}
+	n := 0
+	for _, v := range C {
+		if v > threshold {
+			n++
+		}
+	}
+	if n > 255 {
+		n = 255
+	}
+	return byte(n)
+}
+
+// ContentLuma is Content rating the luma coeffs[0]·r + coeffs[1]·g +
+// coeffs[2]·b rather than the plain mean of the channels, so that
+// posterization is judged as perceived even on saturated images.
+// Zero coeffs select those of Rec. 709, 0.2126, 0.7152 and 0.0722.
+// If src is an *image.RGBA, its pixels are read directly; other
+// sources are read a row at a time, directly for the standard types
+// of rowRGB.
+func ContentLuma(src image.Image, r image.Rectangle, coeffs [3]float64) uint8 {
+	const (
+		threshold = 64
+		one       = 1 << 16
+	)
+	if coeffs == ([3]float64{}) {
+		coeffs = [3]float64{0.2126, 0.7152, 0.0722}
+	}
+	wr, wg, wb := int(coeffs[0]*one+0.5), int(coeffs[1]*one+0.5), int(coeffs[2]*one+0.5)
+	luma := func(r, g, b uint8) uint8 {
+		switch v := (wr*int(r) + wg*int(g) + wb*int(b) + one/2) / one; {
+		case v < 0:
+			return 0
+		case v > 255:
+			return 255
+		default:
+			return uint8(v)
+		}
+	}
+	r = r.Intersect(src.Bounds())
+	C := [256]int{}
+	if rgba, ok := src.(*image.RGBA); ok {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			sp := rgba.PixOffset(r.Min.X, y)
+			for pix, ep := rgba.Pix, sp+r.Dx()*4; sp != ep; sp += 4 {
+				C[luma(pix[sp], pix[sp+1], pix[sp+2])]++
+			}
+		}
+	} else {
+		var buf []uint8
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			buf = rowRGB(src, y, r.Min.X, r.Max.X, buf)
+			for i := 0; i < len(buf); i += 3 {
+				C[luma(buf[i], buf[i+1], buf[i+2])]++
+			}
 		}
 	}
 	n := 0
@@ -63,96 +287,707 @@ func Content(src image.Image, r image.Rectangle) uint8 {
 	return byte(n)
 }
 
-func skinMaskColor(src image.Image, mask draw.Image) (mask0 draw.Image, cover float64) {
+// ContentGrid rates the posterization of each cell of src divided
+// into cols×rows cells, as Content rates a whole region, telling a
+// flat graphic in one corner from a photo in another. Cell edges are
+// spread evenly, so when the size is not divisible cells differ by at
+// most a pixel. The threshold of Content is an absolute count, so
+// cells of fewer pixels rate lower. The result is indexed by row,
+// then column. Cols and rows less than 1 are treated as 1.
+func ContentGrid(src image.Image, cols, rows int) [][]uint8 {
+	const (
+		threshold = 64
+	)
+	if cols < 1 {
+		cols = 1
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	b := src.Bounds()
+	rgba, fast := src.(*image.RGBA)
+	step := 3
+	if fast {
+		step = 4
+	}
+	var buf []uint8
+	grid := make([][]uint8, rows)
+	for j := range grid {
+		grid[j] = make([]uint8, cols)
+		y0, y1 := b.Min.Y+j*b.Dy()/rows, b.Min.Y+(j+1)*b.Dy()/rows
+		for i := range grid[j] {
+			x0, x1 := b.Min.X+i*b.Dx()/cols, b.Min.X+(i+1)*b.Dx()/cols
+			C := [256]int{}
+			for y := y0; y < y1; y++ {
+				if fast {
+					sp := rgba.PixOffset(x0, y)
+					buf = rgba.Pix[sp : sp+(x1-x0)*4]
+				} else {
+					buf = rowRGB(src, y, x0, x1, buf)
+				}
+				for k := 0; k < len(buf); k += step {
+					C[(int(buf[k])+int(buf[k+1])+int(buf[k+2]))/3]++
+				}
+			}
+			n := 0
+			for _, v := range C {
+				if v > threshold {
+					n++
+				}
+			}
+			if n > 255 {
+				n = 255
+			}
+			grid[j][i] = uint8(n)
+		}
+	}
+	return grid
+}
+
+// RegionContent is Content over region, clipped to src, such as the
+// bounds of a skin region from Components. The flat fills of a
+// cartoon rate low where a photographed face does not, so it tells
+// drawn skin from real skin. If src supports SubImage, as the
+// standard image types do, the fast path of Content is taken.
+func RegionContent(src image.Image, region image.Rectangle) uint8 {
+	region = region.Intersect(src.Bounds())
+	if ss, ok := src.(subImager); ok {
+		src = ss.SubImage(region)
+		region = src.Bounds()
+	}
+	return Content(src, region)
+}
+
+// SkinMaskGray is like SkinMask, but returns the mask as an
+// *image.Gray where skin is 255 and everything else is 0, for
+// pipelines that expect a grayscale matte.
+func SkinMaskGray(src image.Image) (mask *image.Gray, cover float64) {
+	mask = image.NewGray(src.Bounds())
+	_, cover = skinMaskColor(src, mask, &Options{})
+	return mask, cover
+}
+
+// SkinMaskChannel sets to 255 one channel of the pixels of dst
+// where src is skin, leaving the other channels and pixels alone, so
+// several detectors can paint one debug image: skin in red, motion
+// in green and so on. Channel is 0 for red, 1 green, 2 blue and 3
+// alpha; other values panic. Src is classified over the bounds of
+// dst.
+func SkinMaskChannel(src image.Image, dst *image.RGBA, channel int) {
+	if channel < 0 || channel > 3 {
+		panic("face: SkinMaskChannel: channel out of range")
+	}
+	r := dst.Bounds()
+	mask := image.NewAlpha(r)
+	skinMaskColor(src, mask, &Options{})
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		dp, mp := dst.PixOffset(r.Min.X, y), mask.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x, dp, mp = x+1, dp+4, mp+1 {
+			if mask.Pix[mp] != 0 {
+				dst.Pix[dp+channel] = 255
+			}
+		}
+	}
+}
+
+// grayAlpha returns an *image.Alpha sharing the pixels of g. Both
+// types store one byte per pixel, so detection writes the same
+// values into either.
+func grayAlpha(g *image.Gray) *image.Alpha {
+	return &image.Alpha{Pix: g.Pix, Stride: g.Stride, Rect: g.Rect}
+}
+
+// to8 converts the 16-bit channels returned by color.Color.RGBA to
+// the 8-bit values the fast paths read from Pix, so that thresholds
+// mean the same on every path. It keeps the high byte, which for
+// the 8-bit image types is exactly the stored value.
+func to8(r, g, b uint32) (uint8, uint8, uint8) {
+	return uint8(r >> 8), uint8(g >> 8), uint8(b >> 8)
+}
+
+// rowRGB returns the 8-bit r, g and b, as read through At, of the
+// pixels x0 through x1-1 of row y of src, in buf grown as needed. The
+// pixels of an *image.RGBA, *image.YCbCr, *image.CMYK, *image.NRGBA
+// or *image.Gray within its bounds are converted in place of At,
+// which boxes each in an interface.
+func rowRGB(src image.Image, y, x0, x1 int, buf []uint8) []uint8 {
+	if n := 3 * (x1 - x0); cap(buf) < n {
+		buf = make([]uint8, n)
+	} else {
+		buf = buf[:n]
+	}
+	b := src.Bounds()
+	lo, hi := x1, x1
+	if y >= b.Min.Y && y < b.Max.Y {
+		lo = clampInt(b.Min.X, x0, x1)
+		hi = clampInt(b.Max.X, lo, x1)
+	}
+	switch src := src.(type) {
+	case *image.RGBA:
+		for x, i := lo, 3*(lo-x0); x < hi; x, i = x+1, i+3 {
+			p := src.Pix[src.PixOffset(x, y):]
+			buf[i], buf[i+1], buf[i+2] = p[0], p[1], p[2]
+		}
+	case *image.YCbCr:
+		for x, i := lo, 3*(lo-x0); x < hi; x, i = x+1, i+3 {
+			yi, ci := src.YOffset(x, y), src.COffset(x, y)
+			r, g, b, _ := color.YCbCr{Y: src.Y[yi], Cb: src.Cb[ci], Cr: src.Cr[ci]}.RGBA()
+			buf[i], buf[i+1], buf[i+2] = to8(r, g, b)
+		}
+	case *image.CMYK:
+		for x, i := lo, 3*(lo-x0); x < hi; x, i = x+1, i+3 {
+			p := src.Pix[src.PixOffset(x, y):]
+			r, g, b, _ := color.CMYK{C: p[0], M: p[1], Y: p[2], K: p[3]}.RGBA()
+			buf[i], buf[i+1], buf[i+2] = to8(r, g, b)
+		}
+	case *image.NRGBA:
+		for x, i := lo, 3*(lo-x0); x < hi; x, i = x+1, i+3 {
+			p := src.Pix[src.PixOffset(x, y):]
+			r, g, b, _ := color.NRGBA{p[0], p[1], p[2], p[3]}.RGBA()
+			buf[i], buf[i+1], buf[i+2] = to8(r, g, b)
+		}
+	case *image.Gray:
+		for x, i := lo, 3*(lo-x0); x < hi; x, i = x+1, i+3 {
+			v := src.Pix[src.PixOffset(x, y)]
+			buf[i], buf[i+1], buf[i+2] = v, v, v
+		}
+	default:
+		lo, hi = x1, x1
+	}
+	at := func(x0, x1, i int) {
+		for x := x0; x < x1; x, i = x+1, i+3 {
+			r, g, b, _ := src.At(x, y).RGBA()
+			buf[i], buf[i+1], buf[i+2] = to8(r, g, b)
+		}
+	}
+	at(x0, lo, 0)
+	at(hi, x1, 3*(hi-x0))
+	return buf
+}
+
+// alphaOf returns mask as an *image.Alpha, sharing the pixels of
+// an *image.Gray and otherwise copying it with nonzero alpha as 255.
+func alphaOf(mask image.Image) *image.Alpha {
+	switch mask := mask.(type) {
+	case *image.Alpha:
+		return mask
+	case *image.Gray:
+		return grayAlpha(mask)
+	}
+	r := mask.Bounds()
+	a := image.NewAlpha(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if _, _, _, v := mask.At(x, y).RGBA(); v != 0 {
+				a.Pix[a.PixOffset(x, y)] = 255
+			}
+		}
+	}
+	return a
+}
+
+// coverage returns m/n clamped to [0, 1], or 0 if n is 0.
+func coverage(m, n int) float64 {
+	switch {
+	case n <= 0 || m <= 0:
+		return 0
+	case m >= n:
+		return 1
+	}
+	return float64(m) / float64(n)
+}
+
+func skinMaskColor(src image.Image, mask draw.Image, opt *Options) (mask0 draw.Image, cover float64) {
+	var t time.Time
+	if opt.OnStats != nil {
+		t = time.Now()
+	}
+	mask, m, n := skinMask(src, mask, opt)
+	// m is in units of 1/unit pixel: quarters when supersampling.
+	unit := 1
+	if opt.Supersample {
+		unit = 4
+	}
+	if opt.RecoverHighlights {
+		m += unit * recoverHighlights(src, mask, opt.color())
+	}
+	if opt.NeutralPassthrough {
+		m += unit * bridgeNeutral(src, mask, opt.color())
+	}
+	if opt.OnStats != nil {
+		opt.OnStats(n, (m+unit/2)/unit, time.Since(t))
+	}
+	if opt.LargestOnly {
+		c, _ := largest(Components(alphaOf(mask), Conn8))
+		return mask, coverage(c.Area, n)
+	}
+	return mask, coverage(m, unit*n)
+}
+
+// skinMask writes the detection for src to mask, allocating it if
+// nil, and returns the mask, the number of skin pixels and the number
+// of pixels classified. For opt.Supersample, skin is counted in
+// quarters of a pixel.
+func skinMask(src image.Image, mask draw.Image, opt *Options) (mask0 draw.Image, m, n int) {
 	var amask bool
+	roi := opt.AsConstraint && mask != nil
 	if mask == nil {
 		mask = image.NewAlpha(src.Bounds())
 		amask = true
+	} else if g, ok := mask.(*image.Gray); ok {
+		_, m, n = skinMask(src, grayAlpha(g), opt)
+		return g, m, n
 	} else {
 		_, amask = mask.(*image.Alpha)
 	}
-	if src.Bounds() == mask.Bounds() {
-		if src, ok := src.(*image.RGBA); ok && amask {
-			return skinMaskColorRGBA(src, mask.(*image.Alpha))
+	if y, ok := src.(*image.YCbCr); ok && opt.ChromaUpsample && y.SubsampleRatio == image.YCbCrSubsampleRatio420 {
+		src = upsampleChroma(y)
+	}
+	if opt.Supersample {
+		m, n = skinMaskSupersampled(src, mask, opt, roi)
+		return mask, m, n
+	}
+	skin, isSkin := opt.classify(src)
+	fill := opt.fill()
+	if r := mask.Bounds(); !r.Empty() && r.In(src.Bounds()) {
+		// The fast paths take a nil skin as IsSkin.
+		fast := skin
+		if isSkin {
+			fast = nil
+		}
+		switch src := src.(type) {
+		case *image.RGBA:
+			if amask {
+				m, n = skinMaskColorRGBA(src, mask.(*image.Alpha), fast, fill, roi)
+				return mask, m, n
+			}
+			if dst, ok := mask.(*image.RGBA); ok {
+				c := color.RGBAModel.Convert(opt.color()).(color.RGBA)
+				m, n = skinMaskColorRGBADst(src, dst, fast, c, roi)
+				return mask, m, n
+			}
+		case *image.NRGBA:
+			if amask {
+				m, n = skinMaskColorNRGBA(src, mask.(*image.Alpha), fast, fill, roi)
+				return mask, m, n
+			}
+		case *image.Gray:
+			if amask {
+				m, n = skinMaskColorGray(src, mask.(*image.Alpha), fast, fill, roi)
+				return mask, m, n
+			}
+		case *image.CMYK:
+			if amask {
+				m, n = skinMaskColorCMYK(src, mask.(*image.Alpha), fast, fill, roi)
+				return mask, m, n
+			}
+		case *image.YCbCr:
+			if amask {
+				native := opt.skin == nil && opt.Mode == ModeYCbCr
+				m, n = skinMaskColorYCbCr(src, mask.(*image.Alpha), fast, native, fill, roi)
+				return mask, m, n
+			}
 		}
 	}
+	if amask {
+		m, n = skinMaskColorAlpha(src, mask.(*image.Alpha), skin, fill, roi)
+		return mask, m, n
+	}
+	if a16, ok := mask.(*image.Alpha16); ok {
+		m, n = skinMaskColorAlpha16(src, a16, skin, fill, roi)
+		return mask, m, n
+	}
 
-	const (
-		minR       = 75 << 8 || 75
-		minRGdelta = 20 << 8 || 20
-		maxRGdelta = 90 << 8 || 90
-		maxRGrat   = 2.5
-	)
 	r := mask.Bounds()
-	m := 0
-	for y := r.Min.Y; y <= r.Max.Y; y++ {
-		for x := r.Min.X; x <= r.Max.X; x++ {
-			r, g, _, _ := src.At(x, y).RGBA()
-			if r < minR {
+	c := opt.color()
+	n = r.Dy() * r.Dx()
+	if roi {
+		n = 0
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if roi {
+				if _, _, _, a := mask.At(x, y).RGBA(); a == 0 {
+					continue
+				}
+				n++
+			}
+			r, g, b, _ := src.At(x, y).RGBA()
+			if !skin(to8(r, g, b)) {
+				if roi {
+					mask.Set(x, y, color.Transparent)
+				}
 				continue
 			}
-			if r-g < minRGdelta || r-g > maxRGdelta {
+			mask.Set(x, y, c)
+			m++
+		}
+	}
+	return mask, m, n
+}
+
+// superLevels are the mask values, as fractions of 255, of a pixel
+// with 0 through 4 of its points found to be skin.
+var superLevels = [5]uint8{0, 64, 128, 192, 255}
+
+// skinMaskSupersampled is skinMask for opt.Supersample. Each pixel
+// is classified at four points, (x+¼, y+¼) through (x+¾, y+¾),
+// sampled bilinearly from src in place, and k points found to be
+// skin set the mask to superLevels[k] of the fill or color. m counts
+// skin points, quarters of a pixel, and n the pixels classified.
+func skinMaskSupersampled(src image.Image, mask draw.Image, opt *Options, roi bool) (m, n int) {
+	skin, fill := opt.classifier(src), opt.fill()
+	a, amask := mask.(*image.Alpha)
+	cr, cg, cb, ca := opt.color().RGBA()
+	r := mask.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if roi {
+				if _, _, _, a := mask.At(x, y).RGBA(); a == 0 {
+					continue
+				}
+			}
+			k := 0
+			for _, d := range [4][2]float64{{0.25, 0.25}, {0.75, 0.25}, {0.25, 0.75}, {0.75, 0.75}} {
+				if c, ok := bilinearRGB(src, float64(x)+d[0], float64(y)+d[1]); ok && skin(c[0], c[1], c[2]) {
+					k++
+				}
+			}
+			m, n = m+k, n+1
+			l := uint32(superLevels[k])
+			switch {
+			case k == 0 && !roi:
+				// Left as is, like the other paths.
+			case amask:
+				a.Pix[a.PixOffset(x, y)] = uint8((l*uint32(fill) + 127) / 255)
+			default:
+				q := func(v uint32) uint16 { return uint16((l*v + 127) / 255) }
+				mask.Set(x, y, color.RGBA64{q(cr), q(cg), q(cb), q(ca)})
+			}
+		}
+	}
+	return m, n
+}
+
+// skinMaskColorAlpha is the generic path for an *image.Alpha mask of
+// any bounds. It reads src a row at a time through rowRGB and writes
+// the mask's pixels directly, computing each row's offset once.
+func skinMaskColorAlpha(src image.Image, mask *image.Alpha, skin func(r, g, b uint8) bool, fill uint8, roi bool) (m, n int) {
+	r := mask.Bounds()
+	n = r.Dy() * r.Dx()
+	if roi {
+		n = 0
+	}
+	var buf []uint8
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		mp := mask.PixOffset(r.Min.X, y)
+		buf = rowRGB(src, y, r.Min.X, r.Max.X, buf)
+		for i := 0; i < len(buf); i, mp = i+3, mp+1 {
+			if roi {
+				if mask.Pix[mp] == 0 {
+					continue
+				}
+				n++
+			}
+			if !skin(buf[i], buf[i+1], buf[i+2]) {
+				if roi {
+					mask.Pix[mp] = 0
+				}
 				continue
 			}
-			if float32(r)/float32(g) >= maxRGrat {
+			mask.Pix[mp] = fill
+			m++
+		}
+	}
+	return m, n
+}
+
+// skinMaskColorAlpha16 is skinMaskColorAlpha for an *image.Alpha16
+// mask, writing fill scaled to 16 bits, so 255 becomes 0xffff, as
+// the big-endian pairs of its Pix.
+func skinMaskColorAlpha16(src image.Image, mask *image.Alpha16, skin func(r, g, b uint8) bool, fill uint8, roi bool) (m, n int) {
+	r := mask.Bounds()
+	n = r.Dy() * r.Dx()
+	if roi {
+		n = 0
+	}
+	var buf []uint8
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		mp := mask.PixOffset(r.Min.X, y)
+		buf = rowRGB(src, y, r.Min.X, r.Max.X, buf)
+		for i := 0; i < len(buf); i, mp = i+3, mp+2 {
+			p := mask.Pix[mp : mp+2 : mp+2]
+			if roi {
+				if p[0] == 0 && p[1] == 0 {
+					continue
+				}
+				n++
+			}
+			if !skin(buf[i], buf[i+1], buf[i+2]) {
+				if roi {
+					p[0], p[1] = 0, 0
+				}
 				continue
 			}
-			mask.Set(x, y, color.Opaque)
+			p[0], p[1] = fill, fill
 			m++
 		}
 	}
-	return mask, float64(m) / float64(r.Dy()*r.Dx())
+	return m, n
 }
 
-func skinMaskColorRGBA(src *image.RGBA, mask *image.Alpha) (mask0 *image.Alpha, cover float64) {
-	const (
-		minR       = 75
-		minRGdelta = 20
-		maxRGdelta = 90
-		maxRGrat   = 2.5
-	)
+// skinMaskColorRGBA is the fast path for an *image.RGBA source and
+// an *image.Alpha mask whose bounds lie within those of src. Both are
+// addressed per row through PixOffset, so neither need be
+// origin-based nor packed, and only the pixels of src under the mask
+// are read.
+func skinMaskColorRGBA(src *image.RGBA, mask *image.Alpha, skin func(r, g, b uint8) bool, fill uint8, roi bool) (m, n int) {
+	r := mask.Bounds()
+	n = r.Dy() * r.Dx()
+	if roi {
+		n = 0
+	}
 
+	pix := src.Pix
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp, mp := src.PixOffset(r.Min.X, y), mask.PixOffset(r.Min.X, y)
+		for ep := sp + r.Dx()*4; sp != ep; sp, mp = sp+4, mp+1 {
+			if roi {
+				if mask.Pix[mp] == 0 {
+					continue
+				}
+				n++
+			}
+			var ok bool
+			if skin == nil {
+				ok = IsSkin(pix[sp], pix[sp+1], pix[sp+2])
+			} else {
+				ok = skin(pix[sp], pix[sp+1], pix[sp+2])
+			}
+			if !ok {
+				if roi {
+					mask.Pix[mp] = 0
+				}
+				continue
+			}
+			mask.Pix[mp] = fill
+			m++
+		}
+	}
+	return m, n
+}
+
+// skinMaskColorNRGBA is skinMaskColorRGBA for an *image.NRGBA
+// source, as decoded from most PNGs, premultiplying each pixel as At
+// would.
+func skinMaskColorNRGBA(src *image.NRGBA, mask *image.Alpha, skin func(r, g, b uint8) bool, fill uint8, roi bool) (m, n int) {
 	r := mask.Bounds()
-	if src.Bounds() != r {
-		panic("skinMaskColorRGBA: doesn't support subimage masks")
+	n = r.Dy() * r.Dx()
+	if roi {
+		n = 0
 	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp, mp := src.PixOffset(r.Min.X, y), mask.PixOffset(r.Min.X, y)
+		for ep := sp + r.Dx()*4; sp != ep; sp, mp = sp+4, mp+1 {
+			if roi {
+				if mask.Pix[mp] == 0 {
+					continue
+				}
+				n++
+			}
+			p := src.Pix[sp : sp+4 : sp+4]
+			cr, cg, cb, _ := color.NRGBA{p[0], p[1], p[2], p[3]}.RGBA()
+			var ok bool
+			if skin == nil {
+				ok = IsSkin(to8(cr, cg, cb))
+			} else {
+				ok = skin(to8(cr, cg, cb))
+			}
+			if !ok {
+				if roi {
+					mask.Pix[mp] = 0
+				}
+				continue
+			}
+			mask.Pix[mp] = fill
+			m++
+		}
+	}
+	return m, n
+}
 
-	sp := (r.Min.Y-src.Rect.Min.Y)*src.Stride + (r.Min.X-src.Rect.Min.X)*4
-	ep := r.Dx() * r.Dy() * 4
-	mp := -1
-	n := 0
+// skinMaskColorGray is skinMaskColorRGBA for an *image.Gray source.
+// A gray pixel is its luma in each of r, g and b, so skin is decided
+// once for each of the 256 levels and the pixels are looked up. The
+// classifiers of every Mode rest on chroma and accept no gray level,
+// so a Gray source yields an empty mask; see SelectMode.
+func skinMaskColorGray(src *image.Gray, mask *image.Alpha, skin func(r, g, b uint8) bool, fill uint8, roi bool) (m, n int) {
+	var lut [256]bool
+	if skin == nil {
+		skin = skinRGB
+	}
+	for v := range lut {
+		lut[v] = skin(uint8(v), uint8(v), uint8(v))
+	}
+	r := mask.Bounds()
+	n = r.Dy() * r.Dx()
+	if roi {
+		n = 0
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp, mp := src.PixOffset(r.Min.X, y), mask.PixOffset(r.Min.X, y)
+		for ep := sp + r.Dx(); sp != ep; sp, mp = sp+1, mp+1 {
+			if roi {
+				if mask.Pix[mp] == 0 {
+					continue
+				}
+				n++
+			}
+			if !lut[src.Pix[sp]] {
+				if roi {
+					mask.Pix[mp] = 0
+				}
+				continue
+			}
+			mask.Pix[mp] = fill
+			m++
+		}
+	}
+	return m, n
+}
 
-	for pix := src.Pix; sp != ep; sp += 4 {
-		mp++
-		g := pix[sp+1]
-		r := pix[sp]
-		if r < minR {
-			continue
+// skinMaskColorCMYK is skinMaskColorRGBA for a CMYK source,
+// converting each pixel to RGB in the loop instead of through At.
+func skinMaskColorCMYK(src *image.CMYK, mask *image.Alpha, skin func(r, g, b uint8) bool, fill uint8, roi bool) (m, n int) {
+	r := mask.Bounds()
+	n = r.Dy() * r.Dx()
+	if roi {
+		n = 0
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp, mp := src.PixOffset(r.Min.X, y), mask.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x, sp, mp = x+1, sp+4, mp+1 {
+			if roi {
+				if mask.Pix[mp] == 0 {
+					continue
+				}
+				n++
+			}
+			p := src.Pix[sp : sp+4 : sp+4]
+			var ok bool
+			if skin == nil {
+				ok = IsSkin(color.CMYKToRGB(p[0], p[1], p[2], p[3]))
+			} else {
+				ok = skin(color.CMYKToRGB(p[0], p[1], p[2], p[3]))
+			}
+			if !ok {
+				if roi {
+					mask.Pix[mp] = 0
+				}
+				continue
+			}
+			mask.Pix[mp] = fill
+			m++
 		}
-		if r-g < minRGdelta || r-g > maxRGdelta {
-			continue
+	}
+	return m, n
+}
+
+// skinMaskColorYCbCr is the fast path for an *image.YCbCr source, of
+// any subsampling, and an *image.Alpha mask within its bounds. If
+// native is set, as for ModeYCbCr, the chroma planes are classified
+// directly, with no round trip through RGB; otherwise each pixel is
+// converted to RGB as At would and passed to skin.
+func skinMaskColorYCbCr(src *image.YCbCr, mask *image.Alpha, skin func(r, g, b uint8) bool, native bool, fill uint8, roi bool) (m, n int) {
+	r := mask.Bounds()
+	n = r.Dy() * r.Dx()
+	if roi {
+		n = 0
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		mp := mask.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x, mp = x+1, mp+1 {
+			if roi {
+				if mask.Pix[mp] == 0 {
+					continue
+				}
+				n++
+			}
+			ci := src.COffset(x, y)
+			var ok bool
+			if native {
+				ok = skinChroma(src.Cb[ci], src.Cr[ci])
+			} else {
+				cr, cg, cb, _ := color.YCbCr{Y: src.Y[src.YOffset(x, y)], Cb: src.Cb[ci], Cr: src.Cr[ci]}.RGBA()
+				if skin == nil {
+					ok = IsSkin(to8(cr, cg, cb))
+				} else {
+					ok = skin(to8(cr, cg, cb))
+				}
+			}
+			if !ok {
+				if roi {
+					mask.Pix[mp] = 0
+				}
+				continue
+			}
+			mask.Pix[mp] = fill
+			m++
 		}
-		if float32(r)/float32(g) >= maxRGrat {
-			continue
+	}
+	return m, n
+}
+
+// skinMaskColorRGBADst is skinMaskColorRGBA for an *image.RGBA
+// mask, writing c to skin pixels.
+func skinMaskColorRGBADst(src, mask *image.RGBA, skin func(r, g, b uint8) bool, c color.RGBA, roi bool) (m, n int) {
+	r := mask.Bounds()
+	n = r.Dy() * r.Dx()
+	if roi {
+		n = 0
+	}
+
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp, dp := src.PixOffset(r.Min.X, y), mask.PixOffset(r.Min.X, y)
+		pix, dst := src.Pix[sp:sp+r.Dx()*4], mask.Pix[dp:dp+r.Dx()*4]
+		for i := 0; i < len(pix); i += 4 {
+			if roi {
+				if dst[i+3] == 0 {
+					continue
+				}
+				n++
+			}
+			var ok bool
+			if skin == nil {
+				ok = IsSkin(pix[i], pix[i+1], pix[i+2])
+			} else {
+				ok = skin(pix[i], pix[i+1], pix[i+2])
+			}
+			if !ok {
+				if roi {
+					dst[i], dst[i+1], dst[i+2], dst[i+3] = 0, 0, 0, 0
+				}
+				continue
+			}
+			dst[i], dst[i+1], dst[i+2], dst[i+3] = c.R, c.G, c.B, c.A
+			m++
 		}
-		mask.Pix[mp] = 255
-		n++
 	}
-	return mask, float64(n) / float64(r.Dy()*r.Dx())
+	return m, n
 }
 
-func contentRGBA(src *image.RGBA) uint8 {
+// contentRGBA is Content for the part r of src, which must lie
+// within its bounds.
+func contentRGBA(src *image.RGBA, r image.Rectangle) uint8 {
 	const (
 		threshold = 64
 	)
-	r := src.Bounds()
-	C := [256]byte{}
-	sp := (r.Min.Y-src.Rect.Min.Y)*src.Stride + (r.Min.X-src.Rect.Min.X)*4
-	ep := src.Bounds().Dx() * src.Bounds().Dy() * 4
-	pix := src.Pix
-	for sp != ep {
-		C[(pix[sp]+pix[sp+1]+pix[sp+2])/3]++
-		sp += 4
+	C := [256]int{}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		sp := src.PixOffset(r.Min.X, y)
+		for pix, ep := src.Pix, sp+r.Dx()*4; sp != ep; sp += 4 {
+			C[(int(pix[sp])+int(pix[sp+1])+int(pix[sp+2]))/3]++
+		}
 	}
 	c := 0
 	for _, v := range C {
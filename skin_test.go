@@ -0,0 +1,465 @@
+package face
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSkinMaskRegionInto(t *testing.T) {
+	const sentinel = 77
+	src := randRGBA(image.Rect(-10, -5, 90, 75), 6)
+	full, _ := SkinMask(src, nil)
+	dst := image.NewAlpha(src.Bounds())
+	for i := range dst.Pix {
+		dst.Pix[i] = sentinel
+	}
+	region := image.Rect(3, 8, 41, 30)
+	SkinMaskRegionInto(src, dst, region)
+	r := dst.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			got := dst.AlphaAt(x, y).A
+			want := uint8(sentinel)
+			if (image.Point{x, y}).In(region) {
+				want = full.(*image.Alpha).AlphaAt(x, y).A
+			}
+			if got != want {
+				t.Fatalf("dst at (%d, %d) = %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+// refContent recounts Content through At.
+func refContent(src image.Image, r image.Rectangle) uint8 {
+	var hist [256]int
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			cr, cg, cb, _ := src.At(x, y).RGBA()
+			hist[(cr>>8+cg>>8+cb>>8)/3]++
+		}
+	}
+	n := 0
+	for _, v := range hist {
+		if v > 64 {
+			n++
+		}
+	}
+	if n > 255 {
+		n = 255
+	}
+	return uint8(n)
+}
+
+func TestContentSubImage(t *testing.T) {
+	src := randRGBA(image.Rect(-20, -4, 300, 120), 7)
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 200, 100),
+		image.Rect(-20, 10, 120, 60),
+		image.Rect(150, -4, 300, 120),
+		image.Rect(31, 17, 32, 90),
+	} {
+		sub := src.SubImage(r).(*image.RGBA)
+		if got, want := Content(sub, sub.Bounds()), refContent(src, r); got != want {
+			t.Errorf("Content of crop %v = %d, want %d", r, got, want)
+		}
+		inner := r.Inset(3)
+		if got, want := Content(sub, inner), refContent(src, inner); got != want {
+			t.Errorf("Content of %v within crop %v = %d, want %d", inner, r, got, want)
+		}
+	}
+}
+
+func TestCoverageExtremes(t *testing.T) {
+	for _, tc := range []struct {
+		c    color.RGBA
+		want float64
+	}{
+		{color.RGBA{198, 134, 102, 255}, 1},
+		{color.RGBA{40, 90, 150, 255}, 0},
+	} {
+		src := image.NewRGBA(image.Rect(-3, 2, 61, 35))
+		paint(src, src.Rect, tc.c)
+		for _, in := range []image.Image{src, generic{src}} {
+			if _, cover := SkinMask(in, nil); cover != tc.want {
+				t.Errorf("%T of %v: coverage %v, want exactly %v", in, tc.c, cover, tc.want)
+			}
+		}
+		if cover := SkinCoverage(src); cover != tc.want {
+			t.Errorf("SkinCoverage of %v = %v, want exactly %v", tc.c, cover, tc.want)
+		}
+	}
+}
+
+func TestNegativeOrigin(t *testing.T) {
+	r := image.Rect(-5, -5, 10, 10)
+	src := randRGBA(r, 8)
+	want, n := refMask(src, r)
+	for _, in := range []image.Image{src, generic{src}} {
+		for _, m := range []*image.Alpha{nil, image.NewAlpha(r)} {
+			var mask image.Image
+			var cover float64
+			if m == nil {
+				mask, cover = SkinMask(in, nil)
+			} else {
+				mask, cover = SkinMask(in, m)
+			}
+			if b := mask.Bounds(); b != r {
+				t.Fatalf("%T: mask bounds %v, want %v", in, b, r)
+			}
+			checkMask(t, mask, want)
+			if wc := float64(n) / float64(r.Dx()*r.Dy()); cover != wc {
+				t.Errorf("%T: coverage %v, want %v", in, cover, wc)
+			}
+		}
+	}
+}
+
+func TestPathsAgree(t *testing.T) {
+	r := image.Rect(-2, 3, 70, 51)
+	rgba := randRGBA(r, 9)
+	pal := image.NewPaletted(r, color.Palette{
+		color.RGBA{198, 134, 102, 255}, color.RGBA{40, 90, 150, 255}, color.RGBA{230, 180, 150, 255},
+	})
+	for i := range pal.Pix {
+		pal.Pix[i] = rgba.Pix[4*i] % 3
+	}
+	srcs := []image.Image{rgba, atRGBA64(rgba), pal}
+	for kind := uint8(1); kind < 5; kind++ {
+		srcs = append(srcs, fuzzSource(kind, image.Rect(0, 0, 72, 48), int64(kind)))
+	}
+	for _, src := range srcs {
+		for _, mode := range []Mode{ModeRGB, ModeHSV, ModeLab} {
+			opt := Options{Mode: mode}
+			fast, cf := SkinMaskWith(src, nil, opt)
+			slow, cs := SkinMaskWith(generic{src}, nil, opt)
+			if cf != cs || !equalAlpha(fast.(*image.Alpha), slow.(*image.Alpha)) {
+				t.Errorf("%T in %v: coverage %v through the fast path, %v through At, or masks differ", src, mode, cf, cs)
+			}
+		}
+	}
+}
+
+// atRGBA64 returns a copy of src as an *image.RGBA64.
+func atRGBA64(src image.Image) *image.RGBA64 {
+	r := src.Bounds()
+	dst := image.NewRGBA64(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			dst.Set(x, y, src.At(x, y))
+		}
+	}
+	return dst
+}
+
+// equalAlpha reports whether a and b have the same bounds and pixels.
+func equalAlpha(a, b *image.Alpha) bool {
+	if a.Rect != b.Rect {
+		return false
+	}
+	for y := a.Rect.Min.Y; y < a.Rect.Max.Y; y++ {
+		for x := a.Rect.Min.X; x < a.Rect.Max.X; x++ {
+			if a.AlphaAt(x, y) != b.AlphaAt(x, y) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestEqualNegativeBounds(t *testing.T) {
+	r := image.Rect(-3, -3, 5, 5)
+	src := randRGBA(r, 10)
+	want, n := refMask(src, r)
+	// A mask of the same bounds, but padded: a sub-image of a wider,
+	// taller one, so its Stride exceeds its width.
+	mask := image.NewAlpha(image.Rect(-9, -6, 12, 9)).SubImage(r).(*image.Alpha)
+	cover := SkinMaskRGBA(src, mask)
+	_, cover2 := SkinMask(src, mask)
+	checkMask(t, mask, want)
+	if wc := float64(n) / 64; cover != wc || cover2 != wc {
+		t.Errorf("coverage %v (SkinMaskRGBA), %v (SkinMask), want %v", cover, cover2, wc)
+	}
+}
+
+func TestCMYKConverted(t *testing.T) {
+	rgba, _ := GenerateTestImage(4, 3)
+	src := image.NewCMYK(rgba.Rect)
+	for y := rgba.Rect.Min.Y; y < rgba.Rect.Max.Y; y++ {
+		for x := rgba.Rect.Min.X; x < rgba.Rect.Max.X; x++ {
+			src.Set(x, y, rgba.At(x, y))
+		}
+	}
+	fast, cf := SkinMask(src, nil)
+	slow, cs := SkinMask(generic{src}, nil)
+	if cf != cs || !equalAlpha(fast.(*image.Alpha), slow.(*image.Alpha)) {
+		t.Errorf("CMYK fast path: coverage %v, At path %v, or masks differ", cf, cs)
+	}
+	if _, want := SkinMask(rgba, nil); math.Abs(cf-want) > 0.005 {
+		t.Errorf("CMYK coverage %v, RGBA original %v", cf, want)
+	}
+}
+
+func TestAlpha16Mask(t *testing.T) {
+	src := randRGBA(image.Rect(-4, -3, 60, 40), 7)
+	for _, r := range []image.Rectangle{src.Rect, image.Rect(-2, 0, 50, 33)} {
+		for _, s := range []image.Image{src, generic{src}} {
+			a, ac := SkinMask(s, image.NewAlpha(r))
+			a16, a16c := SkinMask(s, image.NewAlpha16(r))
+			if ac != a16c {
+				t.Errorf("%T in %v: Alpha16 coverage %v, Alpha %v", s, r, a16c, ac)
+			}
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					v := a.(*image.Alpha).AlphaAt(x, y).A
+					if w := a16.(*image.Alpha16).Alpha16At(x, y).A; w != uint16(v)*0x101 {
+						t.Fatalf("%T at (%d, %d): Alpha16 %#04x, Alpha %#02x", s, x, y, w, v)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestSkinCoverageAllocs(t *testing.T) {
+	r := image.Rect(-3, 0, 700, 20)
+	rgba := randRGBA(r, 8)
+	srcs := []image.Image{rgba, atNRGBA(rgba), randYCbCr(r, image.YCbCrSubsampleRatio444, 8), image.NewGray(r), image.NewCMYK(r)}
+	for _, src := range srcs {
+		want := SkinCoverage(generic{src})
+		var got float64
+		if n := testing.AllocsPerRun(10, func() { got = SkinCoverage(src) }); n != 0 {
+			t.Errorf("%T: %v allocations per run", src, n)
+		}
+		if got != want {
+			t.Errorf("%T: coverage %v, through At %v", src, got, want)
+		}
+	}
+}
+
+func TestSupersample(t *testing.T) {
+	// Skin above the diagonal x = y, background below, and a white
+	// gap inside the skin for NeutralPassthrough to bridge.
+	src := image.NewRGBA(image.Rect(-2, 3, 62, 67))
+	r := src.Rect
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := testBg
+			if x-r.Min.X > y-r.Min.Y {
+				c = testSkin
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+	gap := image.Rect(44, 8, 50, 10)
+	paint(src, gap, testWhite)
+
+	level := make(map[uint8]int)
+	for k, l := range superLevels {
+		level[l] = k
+	}
+	for _, bridge := range []bool{false, true} {
+		var pixels, skin int
+		opt := Options{Supersample: true, NeutralPassthrough: bridge, OnStats: func(p, s int, _ time.Duration) { pixels, skin = p, s }}
+		m, cover := SkinMaskWith(src, nil, opt)
+		mask := m.(*image.Alpha)
+		seen := make(map[uint8]bool)
+		quarters := 0
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				v := mask.AlphaAt(x, y).A
+				k, ok := level[v]
+				d := x - r.Min.X - (y - r.Min.Y)
+				edge := d >= -1 && d <= 1 || image.Pt(x, y).In(gap.Inset(-1))
+				if !ok || !edge && k != 0 && k != 4 {
+					t.Fatalf("bridge %v: mask %d at (%d, %d), away from the edges", bridge, v, x, y)
+				}
+				seen[v] = true
+				quarters += k
+			}
+		}
+		if !seen[64] && !seen[128] && !seen[192] {
+			t.Errorf("bridge %v: no intermediate alphas on the diagonal", bridge)
+		}
+		if got := mask.AlphaAt(46, 8).A != 0; got != bridge {
+			t.Errorf("bridge %v: gap set %v", bridge, got)
+		}
+		n := r.Dx() * r.Dy()
+		if want := coverage(quarters, 4*n); cover != want {
+			t.Errorf("bridge %v: coverage %v, want %v", bridge, cover, want)
+		}
+		if pixels != n || skin != (quarters+2)/4 {
+			t.Errorf("bridge %v: OnStats got %d of %d pixels, want %d of %d", bridge, skin, pixels, (quarters+2)/4, n)
+		}
+	}
+}
+
+func TestContentGrid(t *testing.T) {
+	// 100×60 in 3×2 cells, so cells are 33 or 34 pixels wide.
+	r := image.Rect(-5, 7, 95, 67)
+	rgba := randRGBA(r, 15)
+	flat := image.Rect(-5, 7, 28, 37)
+	paint(rgba, flat, color.RGBA{90, 90, 90, 255})
+	// Seven bands of gray, each of at least 90 pixels.
+	bands := image.Rect(28, 7, 61, 37)
+	for x := bands.Min.X; x < bands.Max.X; x++ {
+		v := uint8(20 * ((x - bands.Min.X) / 5))
+		paint(rgba, image.Rect(x, bands.Min.Y, x+1, bands.Max.Y), color.RGBA{v, v, v, 255})
+	}
+	for _, src := range []image.Image{rgba, generic{rgba}} {
+		grid := ContentGrid(src, 3, 2)
+		if len(grid) != 2 || len(grid[0]) != 3 || len(grid[1]) != 3 {
+			t.Fatalf("%T: grid is %d rows, want 2×3", src, len(grid))
+		}
+		for j, row := range grid {
+			for i, got := range row {
+				cell := image.Rect(r.Min.X+i*100/3, r.Min.Y+j*30, r.Min.X+(i+1)*100/3, r.Min.Y+(j+1)*30)
+				if want := refContent(rgba, cell); got != want {
+					t.Errorf("%T: cell %d, %d of %v rates %d, want %d", src, j, i, cell, got, want)
+				}
+			}
+		}
+		if grid[0][0] != 1 || grid[0][1] != 7 {
+			t.Errorf("%T: flat cell rates %d and banded cell %d, want 1 and 7", src, grid[0][0], grid[0][1])
+		}
+		if g := ContentGrid(src, 0, -1); len(g) != 1 || len(g[0]) != 1 || g[0][0] != refContent(rgba, r) {
+			t.Errorf("%T: ContentGrid(0, -1) = %v, want the whole image as one cell", src, g)
+		}
+	}
+}
+
+func TestRegionContent(t *testing.T) {
+	r := image.Rect(-10, 0, 110, 80)
+	rgba := randRGBA(r, 16)
+	flat := image.Rect(0, 10, 40, 50)
+	paint(rgba, flat, testSkin)
+	for _, src := range []image.Image{rgba, generic{rgba}} {
+		if got := RegionContent(src, flat); got != 1 {
+			t.Errorf("%T: flat region rates %d, want 1", src, got)
+		}
+		// Clipped to the bounds of src.
+		region := image.Rect(20, -20, 200, 90)
+		if got, want := RegionContent(src, region), refContent(rgba, region.Intersect(r)); got != want || got < 2 {
+			t.Errorf("%T: region %v rates %d, want %d", src, region, got, want)
+		}
+		if got := RegionContent(src, image.Rect(200, 200, 300, 300)); got != 0 {
+			t.Errorf("%T: region outside src rates %d, want 0", src, got)
+		}
+	}
+}
+
+func TestSkinMaskStream(t *testing.T) {
+	r := image.Rect(-3, 5, 61, 45)
+	rgba := randRGBA(r, 22)
+	for _, src := range []image.Image{
+		rgba,
+		generic{rgba},
+		randYCbCr(r, image.YCbCrSubsampleRatio420, 22),
+	} {
+		m, want := SkinMask(src, nil)
+		mask := m.(*image.Alpha)
+		next := r.Min.Y
+		cover := SkinMaskStream(src, func(y int, row []uint8) bool {
+			if y != next || len(row) != r.Dx() {
+				t.Fatalf("%T: row %d of %d pixels, want row %d of %d", src, y, len(row), next, r.Dx())
+			}
+			if !bytes.Equal(row, mask.Pix[mask.PixOffset(r.Min.X, y):][:r.Dx()]) {
+				t.Fatalf("%T: row %d differs from SkinMask", src, y)
+			}
+			next++
+			return true
+		})
+		if next != r.Max.Y || cover != want {
+			t.Errorf("%T: stopped before row %d with coverage %v, want %d and %v", src, next, cover, r.Max.Y, want)
+		}
+
+		// Stopped after three rows, the coverage is theirs.
+		rows := 0
+		cover = SkinMaskStream(src, func(int, []uint8) bool { rows++; return rows < 3 })
+		if n, _ := countSet(mask.SubImage(image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+3)), func(x, y int) bool { return true }); rows != 3 || cover != coverage(n, 3*r.Dx()) {
+			t.Errorf("%T: stopped after %d rows with coverage %v, want 3 and %v", src, rows, cover, coverage(n, 3*r.Dx()))
+		}
+	}
+	called := false
+	if cover := SkinMaskStream(image.NewRGBA(image.Rectangle{}), func(int, []uint8) bool { called = true; return true }); called || cover != 0 {
+		t.Errorf("empty source: onRow called %v, coverage %v; want false, 0", called, cover)
+	}
+}
+
+func TestSkinMaskChannel(t *testing.T) {
+	src := randRGBA(image.Rect(-4, 2, 36, 32), 24)
+	m, _ := SkinMask(src, nil)
+	for channel := 0; channel < 4; channel++ {
+		// Dst covers part of src and extends past it.
+		dst := image.NewRGBA(image.Rect(6, -8, 46, 22))
+		paint(dst, dst.Rect, color.RGBA{10, 20, 30, 40})
+		SkinMaskChannel(src, dst, channel)
+		for y := dst.Rect.Min.Y; y < dst.Rect.Max.Y; y++ {
+			for x := dst.Rect.Min.X; x < dst.Rect.Max.X; x++ {
+				want := [4]uint8{10, 20, 30, 40}
+				if image.Pt(x, y).In(src.Rect) && isSet(m, x, y) {
+					want[channel] = 255
+				}
+				if p := dst.Pix[dst.PixOffset(x, y):][:4]; !bytes.Equal(p, want[:]) {
+					t.Fatalf("channel %d: (%d, %d) = %v, want %v", channel, x, y, p, want)
+				}
+			}
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("no panic for channel 4")
+		}
+	}()
+	SkinMaskChannel(src, image.NewRGBA(src.Rect), 4)
+}
+
+func TestSkinMaskGray(t *testing.T) {
+	for _, src := range []image.Image{
+		randRGBA(image.Rect(-4, 2, 36, 32), 25),
+		randYCbCr(image.Rect(3, 1, 43, 31), image.YCbCrSubsampleRatio422, 25),
+	} {
+		m, want := SkinMask(src, nil)
+		mask, cover := SkinMaskGray(src)
+		if mask.Rect != src.Bounds() || cover != want {
+			t.Fatalf("%T: bounds %v, coverage %v; want %v, %v", src, mask.Rect, cover, src.Bounds(), want)
+		}
+		for y := mask.Rect.Min.Y; y < mask.Rect.Max.Y; y++ {
+			for x := mask.Rect.Min.X; x < mask.Rect.Max.X; x++ {
+				want := uint8(0)
+				if isSet(m, x, y) {
+					want = 255
+				}
+				if got := mask.GrayAt(x, y).Y; got != want {
+					t.Fatalf("%T: (%d, %d) = %d, want %d", src, x, y, got, want)
+				}
+			}
+		}
+	}
+	if mask, cover := SkinMaskGray(image.NewRGBA(image.Rectangle{})); !mask.Rect.Empty() || cover != 0 {
+		t.Errorf("empty source: %v, %v; want an empty mask, 0", mask.Rect, cover)
+	}
+}
+
+func TestContentLumaNonRGBA(t *testing.T) {
+	r := image.Rect(-4, 3, 156, 123)
+	for kind := uint8(1); kind <= 5; kind++ {
+		src := fuzzSource(kind, r, int64(kind))
+		if kind == 5 {
+			src = generic{src}
+		}
+		for _, coeffs := range [][3]float64{{}, {0.299, 0.587, 0.114}, {1, 0, 0}} {
+			// The region extends past src, which clips it.
+			region := image.Rect(10, -10, 200, 100)
+			got, want := ContentLuma(src, region, coeffs), ContentLuma(atRGBA(src), region, coeffs)
+			if got != want || got == 0 {
+				t.Errorf("%T, coeffs %v: ContentLuma = %d, want %d, not 0", src, coeffs, got, want)
+			}
+		}
+	}
+}
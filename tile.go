@@ -0,0 +1,88 @@
+package face
+
+import (
+	"image"
+)
+
+// TileDetector accumulates skin detection over an image supplied in
+// tiles, for images too large to hold in memory. Only running
+// totals and a coarse density grid are kept; tiles are not retained
+// after AddTile returns. A TileDetector must not be used by more than
+// one goroutine at a time.
+type TileDetector struct {
+	bounds image.Rectangle
+	cell   int
+	cols   int
+	skin   []int // per cell
+	seen   []int // per cell
+	n, m   int   // pixels seen and skin pixels
+}
+
+// NewTileDetector returns a TileDetector for an image with the given
+// bounds, keeping a density grid of cell×cell pixel cells. A cell
+// less than 1 is treated as 1.
+func NewTileDetector(bounds image.Rectangle, cell int) *TileDetector {
+	if cell < 1 {
+		cell = 1
+	}
+	cols := (bounds.Dx() + cell - 1) / cell
+	rows := (bounds.Dy() + cell - 1) / cell
+	return &TileDetector{
+		bounds: bounds,
+		cell:   cell,
+		cols:   cols,
+		skin:   make([]int, cols*rows),
+		seen:   make([]int, cols*rows),
+	}
+}
+
+// AddTile runs detection on img and folds the result into the
+// totals. The tile covers r within the full image: the pixel at
+// img.Bounds().Min corresponds to r.Min. Parts of the tile outside
+// r or outside the image bounds are ignored. Adding a region twice
+// counts it twice.
+func (t *TileDetector) AddTile(r image.Rectangle, img image.Image) {
+	ib := img.Bounds()
+	delta := r.Min.Sub(ib.Min)
+	r = r.Intersect(ib.Add(delta)).Intersect(t.bounds)
+	if r.Empty() {
+		return
+	}
+	mask, _ := skinMaskColor(img, image.NewAlpha(r.Sub(delta)), &Options{})
+	a := mask.(*image.Alpha)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		mp := a.PixOffset(r.Min.X-delta.X, y-delta.Y)
+		row := (y - t.bounds.Min.Y) / t.cell * t.cols
+		for x := r.Min.X; x < r.Max.X; x, mp = x+1, mp+1 {
+			c := row + (x-t.bounds.Min.X)/t.cell
+			t.seen[c]++
+			t.n++
+			if a.Pix[mp] != 0 {
+				t.skin[c]++
+				t.m++
+			}
+		}
+	}
+}
+
+// Result returns the fraction of pixels seen so far that are skin,
+// and the same fraction per grid cell, indexed as density[row][col].
+// Cells with no pixels seen have a density of 0.
+func (t *TileDetector) Result() (cover float64, density [][]float64) {
+	if t.n != 0 {
+		cover = float64(t.m) / float64(t.n)
+	}
+	if t.cols == 0 {
+		return cover, nil
+	}
+	density = make([][]float64, len(t.seen)/t.cols)
+	for i := range density {
+		density[i] = make([]float64, t.cols)
+		for j := range density[i] {
+			if n := t.seen[i*t.cols+j]; n != 0 {
+				density[i][j] = float64(t.skin[i*t.cols+j]) / float64(n)
+			}
+		}
+	}
+	return cover, density
+}
@@ -0,0 +1,48 @@
+package face
+
+import (
+	"image"
+	"image/draw"
+	"testing"
+)
+
+func TestTileDetector(t *testing.T) {
+	r := image.Rect(-5, -5, 95, 75)
+	full := randRGBA(r, 23)
+	m, want := SkinMask(full, nil)
+	mask := m.(*image.Alpha)
+
+	d := NewTileDetector(r, 16)
+	if cover, density := d.Result(); cover != 0 || len(density) != 5 || len(density[0]) != 7 || density[4][6] != 0 {
+		t.Fatalf("before any tile: %v, %d rows; want 0 and a 5×7 grid of 0", cover, len(density))
+	}
+	// Origin-based tiles of 30×25, as decoded one at a time; those
+	// at the right and bottom edges extend past the image.
+	for y := r.Min.Y; y < r.Max.Y; y += 25 {
+		for x := r.Min.X; x < r.Max.X; x += 30 {
+			tr := image.Rect(x, y, x+30, y+25)
+			tile := image.NewRGBA(image.Rect(0, 0, 30, 25))
+			draw.Draw(tile, tile.Rect, full, tr.Min, draw.Src)
+			d.AddTile(tr, tile)
+		}
+	}
+	d.AddTile(image.Rect(200, 200, 230, 225), full) // outside the image
+
+	cover, density := d.Result()
+	if cover != want {
+		t.Errorf("coverage %v, want %v", cover, want)
+	}
+	for i, row := range density {
+		for j, got := range row {
+			cell := image.Rect(j*16, i*16, j*16+16, i*16+16).Add(r.Min).Intersect(r)
+			n, _ := countSet(mask.SubImage(cell), func(x, y int) bool { return true })
+			if want := coverage(n, cell.Dx()*cell.Dy()); got != want {
+				t.Errorf("cell %d, %d: density %v, want %v", i, j, got, want)
+			}
+		}
+	}
+
+	if _, density := NewTileDetector(image.Rectangle{}, 0).Result(); density != nil {
+		t.Errorf("empty bounds: density %v, want nil", density)
+	}
+}